@@ -0,0 +1,83 @@
+package badgerbs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/multiformats/go-base32"
+)
+
+// S3RemoteArchive is the S3-backed RemoteArchive this package ships
+// alongside VFSRemoteArchive: objects live in Bucket (optionally under
+// Prefix) using the same <journalShortCode>/<base32(hash)> key layout
+// VFSRemoteArchive uses on disk, so the two are interchangeable from an
+// operator's point of view. Client is any *s3.Client, so an S3-compatible
+// store (minio, Ceph RGW, etc.) works equally well via its own endpoint
+// resolver -- this type carries no AWS-specific assumptions beyond the SDK
+// client shape.
+type S3RemoteArchive struct {
+	Client *s3.Client
+	Bucket string
+
+	// Prefix, if set, is prepended (with a "/") to every object key,
+	// letting multiple blockstores share one bucket.
+	Prefix string
+}
+
+// NewS3RemoteArchive returns an S3RemoteArchive against bucket using
+// client, with every object keyed under prefix (prefix may be "").
+func NewS3RemoteArchive(client *s3.Client, bucket, prefix string) *S3RemoteArchive {
+	return &S3RemoteArchive{Client: client, Bucket: bucket, Prefix: prefix}
+}
+
+func (a *S3RemoteArchive) objectKey(journalShortCode byte, hash []byte) string {
+	key := fmt.Sprintf("%d/%s", journalShortCode, base32.RawStdEncoding.EncodeToString(hash))
+	if a.Prefix == "" {
+		return key
+	}
+	return a.Prefix + "/" + key
+}
+
+// Put uploads data to <Bucket>/<Prefix>/<journalShortCode>/<base32(hash)>.
+func (a *S3RemoteArchive) Put(ctx context.Context, journalShortCode byte, hash []byte, data []byte) error {
+	key := a.objectKey(journalShortCode, hash)
+	_, err := a.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(a.Bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("uploading s3://%s/%s: %w", a.Bucket, key, err)
+	}
+	return nil
+}
+
+// Get fetches the object previously Put under (journalShortCode, hash),
+// returning (nil, nil) per the RemoteArchive contract if it doesn't exist.
+func (a *S3RemoteArchive) Get(ctx context.Context, journalShortCode byte, hash []byte) ([]byte, error) {
+	key := a.objectKey(journalShortCode, hash)
+	out, err := a.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(a.Bucket),
+		Key:    aws.String(key),
+	})
+
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("fetching s3://%s/%s: %w", a.Bucket, key, err)
+	}
+	defer out.Body.Close() // nolint:errcheck
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading s3://%s/%s: %w", a.Bucket, key, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,37 @@
+package badgerbs
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestVFSAndS3ArchiveKeyLayoutsMatch guards the claim in S3RemoteArchive's
+// doc comment that VFSRemoteArchive and S3RemoteArchive are interchangeable:
+// both must key a given (journalShortCode, hash) pair the same way, modulo
+// path separator vs URL-style "/".
+func TestVFSAndS3ArchiveKeyLayoutsMatch(t *testing.T) {
+	v := &VFSRemoteArchive{Dir: "/archive"}
+	s := &S3RemoteArchive{Bucket: "bucket"}
+
+	hash := []byte{0xde, 0xad, 0xbe, 0xef}
+	const shortCode = byte(1)
+
+	vfsRel, err := filepath.Rel(v.Dir, v.objectPath(shortCode, hash))
+	if err != nil {
+		t.Fatalf("filepath.Rel: %s", err)
+	}
+	if got, want := filepath.ToSlash(vfsRel), s.objectKey(shortCode, hash); got != want {
+		t.Fatalf("VFS key %q != S3 key %q for the same (shortCode, hash)", got, want)
+	}
+}
+
+// TestS3RemoteArchiveObjectKeyPrefix checks Prefix is applied as documented.
+func TestS3RemoteArchiveObjectKeyPrefix(t *testing.T) {
+	withPrefix := &S3RemoteArchive{Bucket: "bucket", Prefix: "mainnet"}
+	withoutPrefix := &S3RemoteArchive{Bucket: "bucket"}
+
+	hash := []byte{0x01}
+	if got, want := withPrefix.objectKey(0, hash), "mainnet/"+withoutPrefix.objectKey(0, hash); got != want {
+		t.Fatalf("objectKey() = %q, want %q", got, want)
+	}
+}
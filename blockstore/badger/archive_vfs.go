@@ -0,0 +1,64 @@
+package badgerbs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/multiformats/go-base32"
+)
+
+// VFSRemoteArchive is the RemoteArchive this package ships out of the box:
+// a plain directory tree, one subdirectory per journalShortCode and one
+// file per hash underneath it. Since NFS mounts present to the OS exactly
+// like any other directory, pointing Dir at one is all an NFS-backed
+// archive needs -- there's no separate NFS implementation.
+type VFSRemoteArchive struct {
+	Dir string
+}
+
+// NewVFSRemoteArchive returns a VFSRemoteArchive rooted at dir, creating it
+// (and any missing parents) if it doesn't already exist.
+func NewVFSRemoteArchive(dir string) (*VFSRemoteArchive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating archive root %s: %w", dir, err)
+	}
+	return &VFSRemoteArchive{Dir: dir}, nil
+}
+
+func (v *VFSRemoteArchive) objectPath(journalShortCode byte, hash []byte) string {
+	return filepath.Join(
+		v.Dir,
+		fmt.Sprintf("%d", journalShortCode),
+		base32.RawStdEncoding.EncodeToString(hash),
+	)
+}
+
+// Put writes data to <Dir>/<journalShortCode>/<base32(hash)>, via a
+// same-directory temp file plus rename so a reader never observes a
+// partially-written object.
+func (v *VFSRemoteArchive) Put(_ context.Context, journalShortCode byte, hash []byte, data []byte) error {
+	p := v.objectPath(journalShortCode, hash)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// Get returns (nil, nil) if no object has been Put under this
+// (journalShortCode, hash) yet, per the RemoteArchive contract.
+func (v *VFSRemoteArchive) Get(_ context.Context, journalShortCode byte, hash []byte) ([]byte, error) {
+	data, err := os.ReadFile(v.objectPath(journalShortCode, hash))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
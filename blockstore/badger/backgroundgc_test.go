@@ -0,0 +1,32 @@
+package badgerbs
+
+import "testing"
+
+// TestBackgroundGCTickSkipsDuringMove guards the fix that made
+// backgroundGCTick skip entirely for every in-progress move state, not
+// just moveStateLock: lockDB only blocks callers for moveStateLock/
+// moveStateRestoring (exercised separately, and not here, since blocking
+// is exactly what it's supposed to do for those two), so without this
+// extra check a background GC round could run RunValueLogGC concurrently
+// with movingGC's own copy of the same value log during the
+// moveStateMoving/moveStateCleanup states, where lockDB lets it straight
+// through.
+func TestBackgroundGCTickSkipsDuringMove(t *testing.T) {
+	bs := newTestBlockstore(t, 0)
+
+	for _, state := range []bsMoveState{moveStateMoving, moveStateCleanup} {
+		bs.moveMx.Lock()
+		bs.moveState = state
+		bs.moveMx.Unlock()
+
+		bs.backgroundGCTick(0.5)
+
+		if stats := bs.GCStats(); stats.RunsCompleted != 0 {
+			t.Fatalf("moveState=%d: backgroundGCTick ran to completion (RunsCompleted=%d) while a move was in progress", state, stats.RunsCompleted)
+		}
+	}
+
+	bs.moveMx.Lock()
+	bs.moveState = moveStateNone
+	bs.moveMx.Unlock()
+}
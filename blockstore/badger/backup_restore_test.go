@@ -0,0 +1,51 @@
+package badgerbs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+)
+
+// TestBackupRestoreRoundTripsColdStore guards against Backup silently
+// dropping objects Compact has migrated to the cold store: a block that
+// only exists in b.cold must still come back via Get after a Backup/Restore
+// round trip into a fresh blockstore.
+func TestBackupRestoreRoundTripsColdStore(t *testing.T) {
+	ctx := context.Background()
+
+	src := newTestBlockstore(t, 1)
+	hotBlk := blocks.NewBlock([]byte("hot block"))
+	if err := src.Put(ctx, hotBlk); err != nil {
+		t.Fatalf("Put hot block: %s", err)
+	}
+
+	coldBlk := blocks.NewBlock([]byte("cold block"))
+	if err := src.Put(ctx, coldBlk); err != nil {
+		t.Fatalf("Put cold-to-be block: %s", err)
+	}
+	hot := map[string]struct{}{}
+	k, _ := src.PooledStorageKey(hotBlk.Cid())
+	hot[string(k)] = struct{}{}
+	if err := src.migrateCold(ctx, hot); err != nil {
+		t.Fatalf("migrateCold: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := src.Backup(ctx, &buf, 0); err != nil {
+		t.Fatalf("Backup: %s", err)
+	}
+
+	dst := newTestBlockstore(t, 0)
+	if err := dst.Restore(ctx, &buf); err != nil {
+		t.Fatalf("Restore: %s", err)
+	}
+
+	if _, err := dst.Get(ctx, hotBlk.Cid()); err != nil {
+		t.Fatalf("Get restored hot block: %s", err)
+	}
+	if _, err := dst.Get(ctx, coldBlk.Cid()); err != nil {
+		t.Fatalf("Get restored cold block: %s (cold store was dropped by Backup/Restore)", err)
+	}
+}
@@ -1,15 +1,23 @@
 package badgerbs
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/RoaringBitmap/roaring"
 	"github.com/dgraph-io/badger/v2"
 	"github.com/dgraph-io/badger/v2/options"
 	badgerstruct "github.com/dgraph-io/badger/v2/pb"
@@ -29,28 +37,133 @@ import (
 	"github.com/filecoin-project/lotus/blockstore"
 )
 
-type supportedMultihash struct {
-	cidMaker         cid.Prefix
-	journalShortCode byte // for blake2b multihashes this saves 3 bytes in the journal, which is 26bil*3 ~~ 72GiB of space for archival nodes at time of writing
+// registryEntry describes one multihash kind a MultihashRegistry accepts:
+// the CID shape it is wrapped in, the single byte used to tag it in the
+// journal, and its digest length.
+type registryEntry struct {
+	prefix           cid.Prefix
+	journalShortCode byte
+	hashLen          int
 }
 
-// hardcoded hash list for now
-// justification 🧵 https://filecoinproject.slack.com/archives/CRK2LKYHW/p1711381656211189?thread_ts=1711264671.316169&cid=CRK2LKYHW
-var supportedMultihashes = map[string]supportedMultihash{
-	"\xA0\xE4\x02\x20": {
-		cid.NewPrefixV1(uint64(multicodec.Raw), uint64(multicodec.Blake2b256)),
-		1,
-	},
-	"\x12\x20": {
-		cid.NewPrefixV1(uint64(multicodec.Raw), uint64(multicodec.Sha2_256)),
-		0,
-	},
+// MultihashRegistry enumerates the multihashes a Blockstore will accept on
+// Put, and the journal short code used to record each of them. It replaces
+// what used to be a hardcoded table, so downstream consumers (non-Filecoin
+// IPLD users, experimental CIDs, Poseidon/other hashes) can configure this
+// blockstore for multihashes we don't know about.
+//
+// Register at construction time, before handing the registry to Open via
+// Options.MultihashRegistry; MultihashRegistry is not safe to mutate
+// concurrently with blockstore operations.
+type MultihashRegistry struct {
+	mu       sync.RWMutex
+	byPrefix map[string]registryEntry
+	byCode   map[byte]registryEntry
+	maxLen   int
+}
+
+// NewMultihashRegistry returns an empty registry. Most callers want
+// DefaultMultihashRegistry instead, unless they need to diverge from the
+// historical Blake2b-256/Sha2-256 behavior entirely.
+func NewMultihashRegistry() *MultihashRegistry {
+	return &MultihashRegistry{
+		byPrefix: make(map[string]registryEntry),
+		byCode:   make(map[byte]registryEntry),
+	}
+}
+
+// DefaultMultihashRegistry returns a registry preserving this package's
+// historical behavior: 256-bit Sha2-256 (short code 0) and Blake2b-256
+// (short code 1) — see 🧵 https://filecoinproject.slack.com/archives/CRK2LKYHW/p1711381656211189?thread_ts=1711264671.316169&cid=CRK2LKYHW
+func DefaultMultihashRegistry() *MultihashRegistry {
+	r := NewMultihashRegistry()
+	if err := r.Register(cid.NewPrefixV1(uint64(multicodec.Raw), uint64(multicodec.Sha2_256)), 0, legacyHashLen); err != nil {
+		panic(err) // unreachable: the default table cannot conflict with itself.
+	}
+	if err := r.Register(cid.NewPrefixV1(uint64(multicodec.Raw), uint64(multicodec.Blake2b256)), 1, legacyHashLen); err != nil {
+		panic(err) // unreachable: the default table cannot conflict with itself.
+	}
+	return r
+}
+
+// Register adds a supported multihash. prefix identifies the CID shape
+// (codec + multihash type) blocks of this kind are wrapped in;
+// journalShortCode is the single byte tagging journal records for it; and
+// hashLen is the digest length in bytes. Register rejects a journalShortCode
+// that is already registered, or a (multihash type, hashLen) pair that is
+// already registered; the same multihash type with a different hashLen is
+// accepted.
+func (r *MultihashRegistry) Register(prefix cid.Prefix, journalShortCode byte, hashLen int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := string(multihashPrefixKey(uint64(prefix.MhType), hashLen))
+
+	if existing, found := r.byCode[journalShortCode]; found {
+		return xerrors.Errorf("journal short code %d already registered for multihash type 0x%x", journalShortCode, existing.prefix.MhType)
+	}
+	if _, found := r.byPrefix[key]; found {
+		return xerrors.Errorf("multihash type 0x%x with length %d already registered", prefix.MhType, hashLen)
+	}
+
+	e := registryEntry{prefix: prefix, journalShortCode: journalShortCode, hashLen: hashLen}
+	r.byPrefix[key] = e
+	r.byCode[journalShortCode] = e
+	if n := 1 + hashLen; n > r.maxLen {
+		r.maxLen = n
+	}
+	return nil
+}
+
+// lookup resolves a raw multihash (as returned by cid.Cid.Hash) to its
+// registry entry, rejecting anything not registered.
+func (r *MultihashRegistry) lookup(mh []byte) (registryEntry, error) {
+	mhDec, err := multihash.Decode(mh)
+	if err != nil {
+		return registryEntry{}, xerrors.Errorf("unexpected error decoding multihash 0x%X: %s", mh, err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, found := r.byPrefix[string(multihashPrefixKey(uint64(mhDec.Code), mhDec.Length))]
+	if !found {
+		return registryEntry{}, xerrors.Errorf("unsupported multihash type 0x%x with length %d", mhDec.Code, mhDec.Length)
+	}
+	return e, nil
+}
+
+// lookupShortCode resolves a journal short code back to its registry entry.
+func (r *MultihashRegistry) lookupShortCode(code byte) (registryEntry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, found := r.byCode[code]
+	return e, found
+}
+
+// maxRecordLen is the widest journal record ([shortCode][hash]) this
+// registry can produce, used to size scratch buffers conservatively.
+func (r *MultihashRegistry) maxRecordLen() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.maxLen
+}
+
+// multihashPrefixKey encodes a multihash type and digest length exactly as
+// they appear at the start of the wire-format multihash, so it can be used
+// as a map key to recognize supported (type, length) pairs.
+func multihashPrefixKey(mhType uint64, hashLen int) []byte {
+	buf := make([]byte, 2*varint.MaxLenUvarint63)
+	n := varint.PutUvarint(buf, mhType)
+	n += varint.PutUvarint(buf[n:], uint64(hashLen))
+	return buf[:n]
 }
 
 const (
-	supportedHashLen   = 256 / 8
-	mhJournalFilename  = "MultiHashes.bin"
-	mhJournalRecordLen = 1 + supportedHashLen // journalShortCode prefix + 256 bits hash
+	// legacyHashLen is the digest length (bytes) of the two multihashes
+	// DefaultMultihashRegistry registers, preserved for backward compatibility.
+	legacyHashLen     = 256 / 8
+	mhJournalFilename = "MultiHashes.bin"
 )
 
 var (
@@ -84,6 +197,101 @@ type Options struct {
 
 	// Prefix is an optional prefix to prepend to keys. Default: "".
 	Prefix string
+
+	// CompactionThreshold is the number of epochs that must elapse since the
+	// last hot/cold compaction before another one is triggered by Compact.
+	// Zero disables compaction entirely: Compact is then a no-op.
+	CompactionThreshold int64
+
+	// CompactionBoundary is how many epochs behind currentEpoch a Compact
+	// call walks back to when deciding which tipsets are still "hot". Blocks
+	// reachable from tipsets within the boundary stay in the primary
+	// (hot) store; everything else is migrated to the cold store.
+	CompactionBoundary int64
+
+	// LinkExtractor, if set, is called by Compact's reachability walk
+	// (markReachable) with each visited block's CID and raw bytes, and must
+	// return the CIDs it links to. This is the same shallow,
+	// caller-supplied-extractor pattern ExportCAR's selector uses, so this
+	// package keeps no IPLD-schema awareness of its own. Nil makes
+	// markReachable treat every tipset CID as its own leaf, i.e. only the
+	// tipset blocks themselves are kept hot -- fine for testing, but in
+	// practice CompactionThreshold should not be enabled without also
+	// setting this.
+	LinkExtractor func(c cid.Cid, data []byte) ([]cid.Cid, error)
+
+	// RemoteArchive, if set, is the archival tier that ArchiveSince uploads
+	// journaled blocks to. Nil disables archival entirely.
+	RemoteArchive RemoteArchive
+
+	// ReadThroughArchive enables falling back to RemoteArchive on a Get/View
+	// miss in both the hot and (if configured) cold badger instances.
+	ReadThroughArchive bool
+
+	// MembershipFilterPrefixBytes is how many leading bytes of each hash are
+	// indexed by the in-memory membership filter (1-4). Default: 4 (32 bits).
+	MembershipFilterPrefixBytes int
+
+	// MembershipFilterMaxUnique bounds the size of the exact (roaring
+	// bitmap) membership filter; once the unique hash count for a given
+	// journalShortCode exceeds this, the filter for that short code falls
+	// back to a fixed-size probabilistic bloom filter. Zero means unbounded
+	// (always exact).
+	MembershipFilterMaxUnique uint64
+
+	// MembershipFilterFalsePositiveRate is the target false-positive rate
+	// of the probabilistic filter once MembershipFilterMaxUnique is
+	// exceeded. Default: 0.01.
+	MembershipFilterFalsePositiveRate float64
+
+	// NegativeCacheType selects the probabilistic structure a membership
+	// filter switches to once MembershipFilterMaxUnique is exceeded:
+	// "bloom" (the default) or "cuckoo". A cuckoo filter answers mayContain
+	// with fewer probe instructions per lookup at the same false-positive
+	// rate, at the cost of becoming permissive (mayContain always true) for
+	// a given journalShortCode if it's driven past capacity -- see
+	// cuckooFilter.degraded. Unrecognized values behave as "bloom".
+	NegativeCacheType string
+
+	// NegativeCacheSize, when NegativeCacheType is "cuckoo", is the number
+	// of entries the cuckoo filter is sized for; zero sizes it from the
+	// exact filter's count at switchover time (MembershipFilterMaxUnique+1).
+	// Unused for "bloom", which always sizes itself from that count.
+	NegativeCacheSize uint64
+
+	// MultihashRegistry enumerates the multihashes this blockstore accepts
+	// on Put, and how each is tagged in the journal. Nil defaults to
+	// DefaultMultihashRegistry(), preserving this package's historical
+	// Sha2-256/Blake2b-256-only behavior.
+	MultihashRegistry *MultihashRegistry
+
+	// GCInterval, if non-zero, makes Open start a background goroutine that
+	// periodically drives RunValueLogGC to completion, addressing the
+	// unbounded value-log growth badger is prone to under the heavy
+	// Put/DeleteMany churn of chain sync. Zero disables the loop entirely;
+	// CollectGarbage/GCOnce remain available for callers who'd rather drive
+	// GC themselves.
+	GCInterval time.Duration
+
+	// GCThreshold is the discard ratio the background loop passes to
+	// RunValueLogGC. Default: defaultGCThreshold (0.125).
+	GCThreshold float64
+}
+
+// RemoteArchive is a pluggable archival tier that ArchiveSince uploads newly
+// journaled blocks to, and that Get/View optionally read through to on a
+// local miss when Options.ReadThroughArchive is set. Implementations key
+// objects as <journalShortCode>/<base32(hash)>, mirroring the on-disk
+// journal layout; see VFSRemoteArchive for a directory-backed one (a plain
+// local path or an NFS mount both just look like a directory to it) and
+// S3RemoteArchive for an S3 (or S3-compatible) bucket-backed one.
+type RemoteArchive interface {
+	// Put uploads the block identified by (journalShortCode, hash).
+	Put(ctx context.Context, journalShortCode byte, hash []byte, data []byte) error
+
+	// Get fetches the block previously Put under (journalShortCode, hash),
+	// returning (nil, nil) if no such object exists in the archive.
+	Get(ctx context.Context, journalShortCode byte, hash []byte) ([]byte, error)
 }
 
 func DefaultOptions(path string) Options {
@@ -129,6 +337,11 @@ const (
 	moveStateCleanup
 	// moveStateLock signifies that an exclusive lock has been acquired
 	moveStateLock
+	// moveStateRestoring signifies that Restore is replacing the
+	// blockstore's contents wholesale; like moveStateLock it excludes new
+	// lockDB callers, but is reported separately so it's distinguishable
+	// from a move in logs/inspection.
+	moveStateRestoring
 )
 
 type flushWriter interface {
@@ -158,6 +371,27 @@ type Blockstore struct {
 	prefixing bool
 	prefix    []byte
 	prefixLen int
+
+	// cold is the secondary badger instance that compaction migrates
+	// unreachable objects into; it is nil unless CompactionThreshold is set
+	// or Restore has restored a backup that carried a cold section.
+	compactMx        sync.Mutex
+	cold             *badger.DB
+	coldJournal      flushWriter
+	lastCompactEpoch int64
+
+	// filters holds one membership filter per journalShortCode, warmed from
+	// the journal at Open and kept up to date on every PutMany.
+	filtersMx sync.Mutex
+	filters   map[byte]*membershipFilter
+
+	// bgGCStop/bgGCDone bookend the background value-log GC loop Open
+	// starts when Options.GCInterval is set; nil otherwise.
+	bgGCStop chan struct{}
+	bgGCDone chan struct{}
+
+	bgGCStatsMx sync.Mutex
+	bgGCStats   GCStats
 }
 
 var _ blockstore.Blockstore = (*Blockstore)(nil)
@@ -179,7 +413,11 @@ func Open(opts Options) (*Blockstore, error) {
 		return nil, fmt.Errorf("failed to open badger blockstore: %w", err)
 	}
 
-	bs := &Blockstore{db: db, opts: opts}
+	if opts.MultihashRegistry == nil {
+		opts.MultihashRegistry = DefaultMultihashRegistry()
+	}
+
+	bs := &Blockstore{db: db, opts: opts, filters: make(map[byte]*membershipFilter)}
 	if p := opts.Prefix; p != "" {
 		bs.prefixing = true
 		bs.prefix = []byte(p)
@@ -195,9 +433,335 @@ func Open(opts Options) (*Blockstore, error) {
 		}
 	}
 
+	if opts.CompactionThreshold > 0 {
+		if err := bs.openCold(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := bs.loadMembershipFiltersFrom(opts.Dir); err != nil {
+		return nil, xerrors.Errorf("failed to warm membership filters: %w", err)
+	}
+
+	if !opts.ReadOnly && opts.GCInterval > 0 {
+		threshold := opts.GCThreshold
+		if threshold == 0 {
+			threshold = defaultGCThreshold
+		}
+		bs.bgGCStop = make(chan struct{})
+		bs.bgGCDone = make(chan struct{})
+		go bs.runBackgroundGC(opts.GCInterval, threshold)
+	}
+
 	return bs, nil
 }
 
+// coldDir is the directory in which the secondary (cold) badger instance
+// used by Compact lives, rooted next to the primary store.
+func (b *Blockstore) coldDir() string {
+	return filepath.Join(b.opts.Dir, "cold")
+}
+
+// openCold opens (creating if necessary) the cold badger instance and its
+// multihash journal. It is a no-op if the cold store is already open.
+func (b *Blockstore) openCold() error {
+	if b.cold != nil {
+		return nil
+	}
+
+	dir := b.coldDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cold blockstore dir %s: %w", dir, err)
+	}
+
+	coldOpts := b.opts
+	coldOpts.Dir = dir
+	coldOpts.ValueDir = dir
+
+	cold, err := badger.Open(coldOpts.Options)
+	if err != nil {
+		return fmt.Errorf("failed to open cold badger blockstore: %w", err)
+	}
+
+	coldJournal, err := openJournal(dir)
+	if err != nil {
+		cold.Close() // nolint:errcheck
+		return err
+	}
+
+	b.cold = cold
+	b.coldJournal = coldJournal
+	return nil
+}
+
+// MembershipFilterStats describes the observability counters exposed by
+// BloomStats for a single journalShortCode's membership filter.
+type MembershipFilterStats struct {
+	JournalShortCode byte
+	UniqueCount      uint64
+	Probabilistic    bool
+}
+
+// membershipFilter is an in-memory approximate-membership index over the
+// hashes journaled for a single journalShortCode. It starts out as an exact
+// roaring bitmap keyed on a configurable hash prefix, and falls back to a
+// fixed-size probabilistic filter -- bloom or cuckoo, per
+// Options.NegativeCacheType -- once the unique count grows past a
+// configured bound, so memory use stays bounded on very large stores.
+type membershipFilter struct {
+	mu sync.RWMutex
+
+	prefixBytes  int
+	negCacheKind string
+	negCacheSize uint64
+
+	exact *roaring.Bitmap
+
+	probabilistic bool
+	bloomBits     []uint64
+	bloomM        uint64
+	bloomK        uint64
+
+	cuckoo *cuckooFilter
+
+	count uint64
+}
+
+func newMembershipFilter(prefixBytes int, negCacheKind string, negCacheSize uint64) *membershipFilter {
+	if prefixBytes <= 0 || prefixBytes > 4 {
+		prefixBytes = 4
+	}
+	return &membershipFilter{
+		prefixBytes:  prefixBytes,
+		negCacheKind: negCacheKind,
+		negCacheSize: negCacheSize,
+		exact:        roaring.New(),
+	}
+}
+
+// key packs the filter's configured hash prefix into a uint32 roaring/bloom
+// key, clamped to hash's own length: MultihashRegistry.Register allows
+// hashLen shorter than MembershipFilterPrefixBytes (the whole point of a
+// pluggable registry is non-legacy digest sizes), and since a filter's
+// add/mayContain are always called with a hash already trimmed to its
+// registered hashLen, f.prefixBytes can otherwise exceed len(hash) and
+// slice out of bounds.
+func (f *membershipFilter) key(hash []byte) uint32 {
+	n := f.prefixBytes
+	if n > len(hash) {
+		n = len(hash)
+	}
+	var buf [4]byte
+	copy(buf[:n], hash[:n])
+	return binary.BigEndian.Uint32(buf[:])
+}
+
+// add records hash as present, switching to probabilistic mode if maxUnique
+// is exceeded. Must be called from the same critical section as the journal
+// append that records hash, so the filter never lags the journal.
+func (f *membershipFilter) add(hash []byte, maxUnique uint64, fpRate float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := f.key(hash)
+
+	if f.probabilistic {
+		if f.negCacheKind == "cuckoo" {
+			f.cuckoo.add(key)
+		} else if !f.bloomTest(key) {
+			f.bloomAdd(key)
+			f.count++
+		}
+		return
+	}
+
+	if f.exact.Contains(key) {
+		return
+	}
+	f.exact.Add(key)
+	f.count++
+
+	if maxUnique > 0 && f.count > maxUnique {
+		f.switchToProbabilistic(fpRate)
+	}
+}
+
+// switchToProbabilistic replaces the exact roaring bitmap with either a
+// bloom or a cuckoo filter (per f.negCacheKind) sized for f.count entries,
+// migrating existing entries.
+func (f *membershipFilter) switchToProbabilistic(fpRate float64) {
+	if f.negCacheKind == "cuckoo" {
+		capacity := f.negCacheSize
+		if capacity == 0 {
+			capacity = f.count
+		}
+		f.cuckoo = newCuckooFilter(capacity)
+
+		it := f.exact.Iterator()
+		for it.HasNext() {
+			f.cuckoo.add(it.Next())
+		}
+	} else {
+		f.bloomBits, f.bloomM, f.bloomK = newBloom(f.count, fpRate)
+
+		it := f.exact.Iterator()
+		for it.HasNext() {
+			f.bloomAdd(it.Next())
+		}
+	}
+
+	f.probabilistic = true
+	f.exact = nil
+}
+
+// mayContain reports whether hash might have been journaled; false is
+// authoritative, true may be a false positive once in probabilistic mode
+// (or, for an overloaded cuckoo filter, always -- see cuckooFilter.degraded).
+func (f *membershipFilter) mayContain(hash []byte) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	key := f.key(hash)
+	if f.probabilistic {
+		if f.negCacheKind == "cuckoo" {
+			return f.cuckoo.mayContain(key)
+		}
+		return f.bloomTest(key)
+	}
+	return f.exact.Contains(key)
+}
+
+func (f *membershipFilter) stats(shortCode byte) MembershipFilterStats {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	count := f.count
+	if f.probabilistic && f.negCacheKind == "cuckoo" {
+		count = f.cuckoo.count
+	}
+	return MembershipFilterStats{
+		JournalShortCode: shortCode,
+		UniqueCount:      count,
+		Probabilistic:    f.probabilistic,
+	}
+}
+
+func (f *membershipFilter) bloomHashes(key uint32) (h1, h2 uint64) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], key)
+	h := fnv.New64a()
+	h.Write(buf[:]) // nolint:errcheck
+	h1 = h.Sum64()
+	h.Write([]byte{0x5a}) // nolint:errcheck
+	h2 = h.Sum64()
+	return h1, h2
+}
+
+// bloomAdd/bloomTest implement standard Kirsch-Mitzenmacher double hashing
+// over a plain bitset, avoiding the need for k independent hash functions.
+func (f *membershipFilter) bloomAdd(key uint32) {
+	h1, h2 := f.bloomHashes(key)
+	for i := uint64(0); i < f.bloomK; i++ {
+		bit := (h1 + i*h2) % f.bloomM
+		f.bloomBits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (f *membershipFilter) bloomTest(key uint32) bool {
+	h1, h2 := f.bloomHashes(key)
+	for i := uint64(0); i < f.bloomK; i++ {
+		bit := (h1 + i*h2) % f.bloomM
+		if f.bloomBits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// newBloom sizes a bitset for n entries at the given target false-positive
+// rate, using the standard optimal-m/optimal-k bloom filter formulas.
+func newBloom(n uint64, fpRate float64) (bits []uint64, m uint64, k uint64) {
+	if n == 0 {
+		n = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	mf := -float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)
+	m = uint64(math.Ceil(mf))
+	if m == 0 {
+		m = 1
+	}
+	k = uint64(math.Round((float64(m) / float64(n)) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	bits = make([]uint64, (m+63)/64)
+	return bits, m, k
+}
+
+// getFilter returns (creating if necessary) the membership filter for
+// shortCode.
+func (b *Blockstore) getFilter(shortCode byte) *membershipFilter {
+	b.filtersMx.Lock()
+	defer b.filtersMx.Unlock()
+
+	f, ok := b.filters[shortCode]
+	if !ok {
+		f = newMembershipFilter(b.opts.MembershipFilterPrefixBytes, b.opts.NegativeCacheType, b.opts.NegativeCacheSize)
+		b.filters[shortCode] = f
+	}
+	return f
+}
+
+func (b *Blockstore) filterFPRate() float64 {
+	if b.opts.MembershipFilterFalsePositiveRate > 0 {
+		return b.opts.MembershipFilterFalsePositiveRate
+	}
+	return 0.01
+}
+
+// BloomStats returns observability counters for every membership filter
+// currently tracked, one per journalShortCode seen so far.
+func (b *Blockstore) BloomStats() []MembershipFilterStats {
+	b.filtersMx.Lock()
+	defer b.filtersMx.Unlock()
+
+	stats := make([]MembershipFilterStats, 0, len(b.filters))
+	for shortCode, f := range b.filters {
+		stats = append(stats, f.stats(shortCode))
+	}
+	return stats
+}
+
+// loadMembershipFiltersFrom warms b.filters by scanning the multihash
+// journal at dir, discarding whatever was tracked before.
+func (b *Blockstore) loadMembershipFiltersFrom(dir string) error {
+	b.filtersMx.Lock()
+	b.filters = make(map[byte]*membershipFilter)
+	b.filtersMx.Unlock()
+
+	fh, err := os.Open(filepath.Join(dir, mhJournalFilename))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	for {
+		shortCode, hash, err := b.readJournalRecord(fh)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return xerrors.Errorf("failed to read journal while warming membership filters: %w", err)
+		}
+		f := b.getFilter(shortCode)
+		f.add(hash, b.opts.MembershipFilterMaxUnique, b.filterFPRate())
+	}
+	return nil
+}
+
 var fadvWriter func(uintptr) error
 
 func openJournal(dir string) (*os.File, error) {
@@ -236,9 +800,17 @@ func (b *Blockstore) Close() error {
 		b.stateLk.Unlock()
 	}()
 
+	if b.bgGCStop != nil {
+		close(b.bgGCStop)
+	}
+
 	// wait for all accesses to complete
 	b.viewers.Wait()
 
+	if b.bgGCDone != nil {
+		<-b.bgGCDone
+	}
+
 	var err error
 
 	if errDb := b.db.Close(); errDb != nil {
@@ -257,6 +829,23 @@ func (b *Blockstore) Close() error {
 		}
 	}
 
+	if b.cold != nil {
+		if errCold := b.cold.Close(); errCold != nil {
+			errCold = xerrors.Errorf("failure closing the cold badger blockstore: %w", errCold)
+			log.Warn(errCold)
+			if err == nil {
+				err = errCold
+			}
+		}
+		if errCj := b.coldJournal.Close(); errCj != nil {
+			errCj = xerrors.Errorf("failure closing the cold multihash journal: %w", errCj)
+			log.Warn(errCj)
+			if err == nil {
+				err = errCj
+			}
+		}
+	}
+
 	return err
 }
 
@@ -285,7 +874,7 @@ func (b *Blockstore) lockDB() {
 	defer b.moveMx.Unlock()
 
 	if b.rlock == 0 {
-		for b.moveState == moveStateLock {
+		for b.moveState == moveStateLock || b.moveState == moveStateRestoring {
 			b.moveCond.Wait()
 		}
 	}
@@ -326,6 +915,62 @@ func (b *Blockstore) unlockMove(state bsMoveState) {
 // then they must be peristed to the old blockstore.
 // In short, the blockstore must not lose data from new writes during the move.
 func (b *Blockstore) movingGC(ctx context.Context) error {
+	newPath, err := b.adjacentMovePath()
+	if err != nil {
+		return err
+	}
+	return b.moveTo(ctx, newPath, nil)
+}
+
+// adjacentMovePath computes a fresh, timestamped path adjacent to the
+// current blockstore directory -- the destination movingGC relocates to
+// for its in-place, unfiltered compaction.
+func (b *Blockstore) adjacentMovePath() (string, error) {
+	// we resolve symlinks to create the new path in the adjacent to the old path.
+	// this allows the user to symlink the db directory into a separate filesystem.
+	basePath := b.opts.Dir
+	linkPath, err := filepath.EvalSymlinks(basePath)
+	if err != nil {
+		return "", fmt.Errorf("error resolving symlink %s: %w", basePath, err)
+	}
+
+	var newPath string
+	if basePath == linkPath {
+		newPath = basePath
+	} else {
+		// we do this dance to create a name adjacent to the current one, while avoiding clown
+		// shoes with multiple moves (i.e. we can't just take the basename of the linkPath, as it
+		// could have been created in a previous move and have the timestamp suffix, which would then
+		// perpetuate itself.
+		name := filepath.Base(basePath)
+		dir := filepath.Dir(linkPath)
+		newPath = filepath.Join(dir, name)
+	}
+	return fmt.Sprintf("%s.%d", newPath, time.Now().UnixNano()), nil
+}
+
+// MoveTo relocates the blockstore's underlying badger store to newPath,
+// optionally filtering out blocks for which filter returns false along the
+// way -- the "hot repo compaction" use case of pruning a bloated Filecoin/
+// IPLD store in place without downtime. filter may be nil to copy
+// everything, as movingGC does for its adjacent, unfiltered relocation.
+//
+// MoveTo shares movingGC's move-state machine (None -> Moving -> Cleanup,
+// briefly -> Lock for the final swap): Put/PutMany/DeleteMany/View continue
+// to be served throughout. Put/PutMany are dual-written to both the source
+// and the in-progress destination; DeleteMany deletes from the source only,
+// so a block deleted during the move is still present in the destination
+// if the copy already reached it. The multihash journal is replayed into the
+// destination alongside the data so the new store's journal stays
+// consistent. Only the atomic swap at the end excludes new lockDB callers,
+// and only briefly. On success the old directory is removed; on failure
+// the partial destination is torn down and dual-write is disabled.
+func (b *Blockstore) MoveTo(ctx context.Context, newPath string, filter func(cid.Cid) bool) error {
+	return b.moveTo(ctx, newPath, filter)
+}
+
+// moveTo is the move/compaction engine shared by movingGC and MoveTo.
+func (b *Blockstore) moveTo(ctx context.Context, newPath string, filter func(cid.Cid) bool) error {
 	// this inlines moveLock/moveUnlock for the initial state check to prevent a second move
 	// while one is in progress without clobbering state
 	b.moveMx.Lock()
@@ -343,8 +988,6 @@ func (b *Blockstore) movingGC(ctx context.Context) error {
 	b.moveCond.Broadcast()
 	b.moveMx.Unlock()
 
-	var newPath string
-
 	defer func() {
 		b.lockMove()
 
@@ -377,27 +1020,6 @@ func (b *Blockstore) movingGC(ctx context.Context) error {
 		}
 	}()
 
-	// we resolve symlinks to create the new path in the adjacent to the old path.
-	// this allows the user to symlink the db directory into a separate filesystem.
-	basePath := b.opts.Dir
-	linkPath, err := filepath.EvalSymlinks(basePath)
-	if err != nil {
-		return fmt.Errorf("error resolving symlink %s: %w", basePath, err)
-	}
-
-	if basePath == linkPath {
-		newPath = basePath
-	} else {
-		// we do this dance to create a name adjacent to the current one, while avoiding clown
-		// shoes with multiple moves (i.e. we can't just take the basename of the linkPath, as it
-		// could have been created in a previous move and have the timestamp suffix, which would then
-		// perpetuate itself.
-		name := filepath.Base(basePath)
-		dir := filepath.Dir(linkPath)
-		newPath = filepath.Join(dir, name)
-	}
-	newPath = fmt.Sprintf("%s.%d", newPath, time.Now().UnixNano())
-
 	log.Infof("moving blockstore from %s to %s", b.opts.Dir, newPath)
 
 	opts := b.opts
@@ -420,7 +1042,7 @@ func (b *Blockstore) movingGC(ctx context.Context) error {
 	b.unlockMove(moveStateMoving)
 
 	log.Info("copying blockstore")
-	err = b.doCopy(ctx, b.db, b.dbNext, b.mhJournalNext)
+	err = b.doCopy(ctx, b.db, b.dbNext, b.mhJournalNext, filter)
 	if err != nil {
 		return fmt.Errorf("error moving badger blockstore to %s: %w", newPath, err)
 	}
@@ -434,6 +1056,10 @@ func (b *Blockstore) movingGC(ctx context.Context) error {
 	b.mhJournalNext = nil
 	b.unlockMove(moveStateCleanup)
 
+	if err := b.loadMembershipFiltersFrom(newPath); err != nil {
+		log.Warnf("error rebuilding membership filters from %s: %s", newPath, err)
+	}
+
 	if err := dbOld.Close(); err != nil {
 		log.Warnf("error closing old badger db: %s", err)
 	}
@@ -469,62 +1095,334 @@ func (b *Blockstore) movingGC(ctx context.Context) error {
 	return nil
 }
 
-// symlink creates a symlink from path to linkTo; the link is relative if the two are
-// in the same directory
-func symlink(path, linkTo string) error {
-	resolvedPathDir, err := filepath.EvalSymlinks(filepath.Dir(path))
-	if err != nil {
-		return fmt.Errorf("error resolving links in %s: %w", path, err)
+// Compact triggers a splitstore-style hot/cold compaction if enough epochs
+// have elapsed since the last one, i.e. if
+// currentEpoch-lastCompactEpoch >= CompactionThreshold. getTipset is called
+// for every epoch in [currentEpoch-CompactionBoundary, currentEpoch] and must
+// return the CIDs of the tipset at that epoch (or nil if unknown); objects
+// reachable from those CIDs are kept hot, everything else is migrated to the
+// cold store. Compact is a no-op if CompactionThreshold is zero or has not
+// yet elapsed.
+func (b *Blockstore) Compact(ctx context.Context, currentEpoch int64, getTipset func(epoch int64) []cid.Cid) error {
+	if b.opts.CompactionThreshold <= 0 {
+		return nil
 	}
 
-	resolvedLinkDir, err := filepath.EvalSymlinks(filepath.Dir(linkTo))
-	if err != nil {
-		return fmt.Errorf("error resolving links in %s: %w", linkTo, err)
+	b.compactMx.Lock()
+	defer b.compactMx.Unlock()
+
+	if currentEpoch-b.lastCompactEpoch < b.opts.CompactionThreshold {
+		return nil
 	}
 
-	if resolvedPathDir == resolvedLinkDir {
-		path = filepath.Base(path)
+	if err := b.access(); err != nil {
+		return err
 	}
+	defer b.viewers.Done()
 
-	return os.Symlink(path, linkTo)
-}
+	if err := b.openCold(); err != nil {
+		return err
+	}
 
-// doCopy copies a badger blockstore to another
-func (b *Blockstore) doCopy(ctx context.Context, from, to *badger.DB, jrnlFh io.Writer) (defErr error) {
-	batch := to.NewWriteBatch()
-	defer func() {
-		if defErr == nil {
-			defErr = batch.Flush()
-		}
-		if defErr != nil {
-			batch.Cancel()
-		}
-	}()
+	boundaryEpoch := currentEpoch - b.opts.CompactionBoundary
 
-	return iterateBadger(ctx, from, func(kvs []*badgerstruct.KV) error {
-		// check whether context is closed on every kv group
+	hot := make(map[string]struct{})
+	for epoch := boundaryEpoch; epoch <= currentEpoch; epoch++ {
 		if err := ctx.Err(); err != nil {
 			return err
 		}
+		for _, c := range getTipset(epoch) {
+			if err := b.markReachable(c, hot); err != nil {
+				return xerrors.Errorf("error walking tipset at epoch %d: %w", epoch, err)
+			}
+		}
+	}
 
-		jrnlSlab := pool.Get(len(kvs) * mhJournalRecordLen)
-		defer pool.Put(jrnlSlab)
-		jrnl := jrnlSlab[:0]
+	if err := b.migrateCold(ctx, hot); err != nil {
+		return xerrors.Errorf("error migrating cold objects: %w", err)
+	}
 
-		mhBuf := pool.Get(varint.MaxLenUvarint63 + supportedHashLen)
-		defer pool.Put(mhBuf)
+	b.lastCompactEpoch = currentEpoch
+	return nil
+}
 
-		for _, kv := range kvs {
+// markReachable walks the set of blocks reachable from c and adds their
+// storage keys to hot. If b.opts.LinkExtractor is set, it performs a real
+// breadth-first walk: each visited block's CID and raw bytes are handed to
+// the extractor, and the CIDs it returns are visited next -- the same
+// shallow, caller-supplied-extractor pattern ExportCAR's selector uses, so
+// this package still carries no IPLD-schema awareness of its own. Without
+// a LinkExtractor, c is kept hot as its own leaf and nothing is walked,
+// matching this function's behavior before LinkExtractor existed.
+//
+// A node missing from the hot store is looked up in the cold store too,
+// same as Get/View/Has already do: a node this Compact's boundary window
+// re-references may have been migrated to cold by an earlier Compact, and
+// without the fallback the walk would dead-end there, wrongly sweeping its
+// still-reachable children into cold alongside it.
+func (b *Blockstore) markReachable(c cid.Cid, hot map[string]struct{}) error {
+	if b.opts.LinkExtractor == nil {
+		k, pooled := b.PooledStorageKey(c)
+		if pooled {
+			defer KeyPool.Put(k)
+		}
+		hot[string(k)] = struct{}{}
+		return nil
+	}
+
+	queue := []cid.Cid{c}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		k, pooled := b.PooledStorageKey(cur)
+		if _, seen := hot[string(k)]; seen {
+			if pooled {
+				KeyPool.Put(k)
+			}
+			continue
+		}
+
+		var data []byte
+		err := b.db.View(func(txn *badger.Txn) error {
+			val, err := badgerGet(txn, k)
+			if err != nil {
+				return err
+			}
+			if val == nil {
+				return nil // not present in hot; fall back to cold below
+			}
+			data, err = val.badgerItem.ValueCopy(nil)
+			return err
+		})
+
+		// A node walked by a prior Compact may already have been migrated
+		// to cold; without this fallback, re-referencing it in a later
+		// Compact (e.g. after a reorg) dead-ends the walk right here, so
+		// its still-live children never get marked hot and are wrongly
+		// swept into cold themselves by this same migrateCold call.
+		if err == nil && data == nil && b.cold != nil {
+			err = b.cold.View(func(txn *badger.Txn) error {
+				val, err := badgerGet(txn, k)
+				if err != nil {
+					return err
+				}
+				if val == nil {
+					return nil // not present in cold either; nothing further to walk
+				}
+				data, err = val.badgerItem.ValueCopy(nil)
+				return err
+			})
+		}
+
+		hot[string(k)] = struct{}{}
+		if pooled {
+			KeyPool.Put(k)
+		}
+
+		if err != nil {
+			return xerrors.Errorf("failed to read %s while walking reachable set: %w", cur, err)
+		}
+		if data == nil {
+			continue
+		}
+
+		children, err := b.opts.LinkExtractor(cur, data)
+		if err != nil {
+			return xerrors.Errorf("link extractor failed for %s: %w", cur, err)
+		}
+		queue = append(queue, children...)
+	}
+	return nil
+}
+
+// migrateCold moves every hot-db entry whose key is not in hot to the cold
+// store. It takes no exclusive lock at all: for its whole duration it holds
+// the same non-exclusive lockDB every other accessor already goes through,
+// so it runs fully concurrently with Put/Get/View, while a MoveTo/movingGC
+// swap of the underlying *badger.DB waits for it to finish rather than
+// closing the db out from under it. A write landing on a key we are about to migrate
+// simply wins: the delete phase re-checks each key's presence right before
+// deleting it, and since this is a content-addressed store a surviving key
+// still holds the bytes we already copied to cold, so skipping an
+// already-deleted key is always safe.
+func (b *Blockstore) migrateCold(ctx context.Context, hot map[string]struct{}) error {
+	if err := b.access(); err != nil {
+		return err
+	}
+	defer b.viewers.Done()
+
+	b.lockDB()
+	defer b.unlockDB()
+
+	toMigrate := make([][]byte, 0)
+	if err := b.db.View(func(txn *badger.Txn) error {
+		opts := badger.IteratorOptions{PrefetchSize: 100}
+		if b.prefixing {
+			opts.Prefix = b.prefix
+		}
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			k := append([]byte{}, iter.Item().Key()...)
+			if _, ok := hot[string(k)]; ok {
+				continue
+			}
+			toMigrate = append(toMigrate, k)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	coldBatch := b.cold.NewWriteBatch()
+	defer coldBatch.Cancel()
+
+	jrnl := make([]byte, 0, len(toMigrate)*b.opts.MultihashRegistry.maxRecordLen())
+	migrated := make([][]byte, 0, len(toMigrate))
+
+	if err := b.db.View(func(txn *badger.Txn) error {
+		for _, k := range toMigrate {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			val, err := badgerGet(txn, k)
+			if err != nil {
+				return err
+			}
+			if val == nil {
+				// raced with a concurrent delete; nothing to migrate.
+				continue
+			}
+			v, err := val.badgerItem.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if err := coldBatch.Set(k, v); err != nil {
+				return err
+			}
+			migrated = append(migrated, k)
+
+			mh, err := base32.RawStdEncoding.DecodeString(string(k[b.prefixLen:]))
+			if err != nil {
+				return xerrors.Errorf("undecodeable key 0x%X: %w", k[b.prefixLen:], err)
+			}
+			e, err := b.opts.MultihashRegistry.lookup(mh)
+			if err != nil {
+				return xerrors.Errorf("unsupported multihash for key 0x%X: %w", k[b.prefixLen:], err)
+			}
+			jrnl = append(jrnl, e.journalShortCode)
+			jrnl = append(jrnl, mh[len(mh)-e.hashLen:]...)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := coldBatch.Flush(); err != nil {
+		return xerrors.Errorf("failed to flush cold batch: %w", err)
+	}
+	if len(jrnl) > 0 {
+		if _, err := b.coldJournal.Write(jrnl); err != nil {
+			return xerrors.Errorf("failed to write multihashes to cold journal: %w", err)
+		}
+	}
+
+	hotBatch := b.db.NewWriteBatch()
+	defer hotBatch.Cancel()
+
+	if err := b.db.View(func(txn *badger.Txn) error {
+		for _, k := range migrated {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			val, err := badgerGet(txn, k)
+			if err != nil {
+				return err
+			}
+			if val == nil {
+				// already deleted (or migrated again) since we copied it; leave it be.
+				continue
+			}
+			if err := hotBatch.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := hotBatch.Flush(); err != nil {
+		return xerrors.Errorf("failed to flush hot batch: %w", err)
+	}
+
+	return nil
+}
+
+// symlink creates a symlink from path to linkTo; the link is relative if the two are
+// in the same directory
+func symlink(path, linkTo string) error {
+	resolvedPathDir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("error resolving links in %s: %w", path, err)
+	}
+
+	resolvedLinkDir, err := filepath.EvalSymlinks(filepath.Dir(linkTo))
+	if err != nil {
+		return fmt.Errorf("error resolving links in %s: %w", linkTo, err)
+	}
+
+	if resolvedPathDir == resolvedLinkDir {
+		path = filepath.Base(path)
+	}
+
+	return os.Symlink(path, linkTo)
+}
+
+// doCopy copies a badger blockstore to another. If filter is non-nil, a kv
+// pair is copied only when filter returns true for the CID its key decodes
+// to, letting callers compact out blocks they no longer want (see MoveTo).
+func (b *Blockstore) doCopy(ctx context.Context, from, to *badger.DB, jrnlFh io.Writer, filter func(cid.Cid) bool) (defErr error) {
+	batch := to.NewWriteBatch()
+	defer func() {
+		if defErr == nil {
+			defErr = batch.Flush()
+		}
+		if defErr != nil {
+			batch.Cancel()
+		}
+	}()
+
+	return iterateBadger(ctx, from, func(kvs []*badgerstruct.KV) error {
+		// check whether context is closed on every kv group
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		jrnlSlab := pool.Get(len(kvs) * b.opts.MultihashRegistry.maxRecordLen())
+		defer pool.Put(jrnlSlab)
+		jrnl := jrnlSlab[:0]
+
+		mhBuf := pool.Get(2*varint.MaxLenUvarint63 + b.opts.MultihashRegistry.maxRecordLen())
+		defer pool.Put(mhBuf)
+
+		for _, kv := range kvs {
 
 			n, err := base32.RawStdEncoding.Decode(mhBuf, kv.Key[b.prefixLen:])
 			if err != nil {
 				return xerrors.Errorf("undecodeable key 0x%X: %s", kv.Key[b.prefixLen:], err)
 			}
-			smh, err := isMultihashSupported(mhBuf[:n])
+			e, err := b.opts.MultihashRegistry.lookup(mhBuf[:n])
 			if err != nil {
 				return xerrors.Errorf("unsupported multihash for key 0x%X: %w", kv.Key[b.prefixLen:], err)
 			}
 
+			if filter != nil && !filter(cid.NewCidV1(e.prefix.Codec, multihash.Multihash(mhBuf[:n]))) {
+				continue
+			}
+
 			if err := batch.Set(kv.Key, kv.Value); err != nil {
 				return err
 			}
@@ -532,8 +1430,8 @@ func (b *Blockstore) doCopy(ctx context.Context, from, to *badger.DB, jrnlFh io.
 			// add a journal record
 			// NOTE: this could very well result in duplicates
 			// there isn't much we can do about this right now...
-			jrnl = append(jrnl, smh.journalShortCode)
-			jrnl = append(jrnl, mhBuf[n-supportedHashLen:n]...)
+			jrnl = append(jrnl, e.journalShortCode)
+			jrnl = append(jrnl, mhBuf[n-e.hashLen:n]...)
 		}
 
 		if _, err := jrnlFh.Write(jrnl); err != nil {
@@ -611,147 +1509,873 @@ func (b *Blockstore) onlineGC(ctx context.Context, threshold float64, checkFreq
 	if nworkers < 2 {
 		nworkers = 2
 	}
-	if nworkers > 7 { // max out at 1 goroutine per badger level
-		nworkers = 7
+	if nworkers > 7 { // max out at 1 goroutine per badger level
+		nworkers = 7
+	}
+
+	err := b.db.Flatten(nworkers)
+	if err != nil {
+		return err
+	}
+	checkTick := time.NewTimer(checkFreq)
+	defer checkTick.Stop()
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-checkTick.C:
+			err = check()
+			checkTick.Reset(checkFreq)
+		default:
+			err = b.db.RunValueLogGC(threshold)
+		}
+	}
+
+	if err == badger.ErrNoRewrite {
+		// not really an error in this case, it signals the end of GC
+		return nil
+	}
+
+	return err
+}
+
+// GCStats exposes observability counters for the background value-log GC
+// loop Open starts when Options.GCInterval is set.
+type GCStats struct {
+	// RunsCompleted is the number of background rounds in which
+	// RunValueLogGC rewrote at least one value-log file before returning
+	// badger.ErrNoRewrite (or an error); a round that found nothing to
+	// rewrite is not counted.
+	RunsCompleted uint64
+
+	// ReclaimedBytes is the cumulative decrease in Size() the background
+	// loop has observed across its lifetime. It can be negative over any
+	// given window, since Size() also reflects concurrent Put/DeleteMany
+	// traffic the loop has no visibility into.
+	ReclaimedBytes int64
+
+	// LastRunDuration is the wall-clock time the most recently counted
+	// round took.
+	LastRunDuration time.Duration
+}
+
+// GCStats returns a snapshot of the background GC loop's counters. It is
+// the zero value if Options.GCInterval was not set.
+func (b *Blockstore) GCStats() GCStats {
+	b.bgGCStatsMx.Lock()
+	defer b.bgGCStatsMx.Unlock()
+	return b.bgGCStats
+}
+
+// runBackgroundGC drives RunValueLogGC to completion once per interval
+// until bgGCStop is closed. It is started by Open and stopped by Close.
+func (b *Blockstore) runBackgroundGC(interval time.Duration, threshold float64) {
+	defer close(b.bgGCDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.bgGCStop:
+			return
+		case <-ticker.C:
+			b.backgroundGCTick(threshold)
+		}
+	}
+}
+
+// backgroundGCTick runs one round of RunValueLogGC to completion, skipping
+// entirely if the blockstore is closing/closed or a move is in progress.
+func (b *Blockstore) backgroundGCTick(threshold float64) {
+	if err := b.access(); err != nil {
+		// closing or closed; nothing to do.
+		return
+	}
+	defer b.viewers.Done()
+
+	b.lockDB()
+	defer b.unlockDB()
+
+	// lockDB only blocks for moveStateLock/moveStateRestoring, not for a
+	// move that's already mid-copy (moveStateMoving/moveStateCleanup), so
+	// without this check a background GC round could run its own
+	// value-log rewrite concurrently with movingGC's copy of the same
+	// value log -- skip entirely rather than race it.
+	b.moveMx.Lock()
+	skip := b.moveState != moveStateNone
+	b.moveMx.Unlock()
+	if skip {
+		return
+	}
+
+	sizeBefore, _ := b.Size()
+	start := time.Now()
+
+	var ranAny bool
+	for {
+		err := b.db.RunValueLogGC(threshold)
+		if err == badger.ErrNoRewrite {
+			break
+		}
+		if err != nil {
+			log.Warnf("background value-log GC round failed: %s", err)
+			break
+		}
+		ranAny = true
+	}
+
+	if !ranAny {
+		return
+	}
+
+	duration := time.Since(start)
+	sizeAfter, _ := b.Size()
+
+	b.bgGCStatsMx.Lock()
+	b.bgGCStats.RunsCompleted++
+	b.bgGCStats.ReclaimedBytes += sizeBefore - sizeAfter
+	b.bgGCStats.LastRunDuration = duration
+	b.bgGCStatsMx.Unlock()
+}
+
+// CollectGarbage compacts and runs garbage collection on the value log;
+// implements the BlockstoreGC trait
+func (b *Blockstore) CollectGarbage(ctx context.Context, opts ...blockstore.BlockstoreGCOption) error {
+	if err := b.access(); err != nil {
+		return err
+	}
+	defer b.viewers.Done()
+
+	var options blockstore.BlockstoreGCOptions
+	for _, opt := range opts {
+		err := opt(&options)
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.FullGC {
+		return b.movingGC(ctx)
+	}
+	threshold := options.Threshold
+	if threshold == 0 {
+		threshold = defaultGCThreshold
+	}
+	checkFreq := options.CheckFreq
+	if checkFreq < 30*time.Second { // disallow checking more frequently than block time
+		checkFreq = 30 * time.Second
+	}
+	check := options.Check
+	if check == nil {
+		check = func() error {
+			return nil
+		}
+	}
+	return b.onlineGC(ctx, threshold, checkFreq, check)
+}
+
+// GCOnce runs garbage collection on the value log;
+// implements BlockstoreGCOnce trait
+func (b *Blockstore) GCOnce(ctx context.Context, opts ...blockstore.BlockstoreGCOption) error {
+	if err := b.access(); err != nil {
+		return err
+	}
+	defer b.viewers.Done()
+
+	var options blockstore.BlockstoreGCOptions
+	for _, opt := range opts {
+		err := opt(&options)
+		if err != nil {
+			return err
+		}
+	}
+	if options.FullGC {
+		return xerrors.Errorf("FullGC option specified for GCOnce but full GC is non incremental")
+	}
+
+	threshold := options.Threshold
+	if threshold == 0 {
+		threshold = defaultGCThreshold
+	}
+
+	b.lockDB()
+	defer b.unlockDB()
+
+	// Note no compaction needed before single GC as we will hit at most one vlog anyway
+	err := b.db.RunValueLogGC(threshold)
+	if err == badger.ErrNoRewrite {
+		// not really an error in this case, it signals the end of GC
+		return nil
+	}
+
+	return err
+}
+
+// Size returns the aggregate size of the blockstore
+func (b *Blockstore) Size() (int64, error) {
+	var size int64
+
+	// do not use b.db.Size(): since we are storing data outside of usual
+	// badger files it can't be accurate anyway. Just sum up the dir sizes
+	// without even trying to lock the db
+	//
+	// moreover: badger reports a 0 size on symlinked directories anyway
+	dir := b.opts.Dir
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		finfo, _ := os.Stat(path) // ignore potential error: if we are in a compaction an .sst might disappear on us
+		size += finfo.Size()
+	}
+
+	return size, nil
+}
+
+// cidFromJournalRecord reconstructs the CID that a journal record refers to,
+// given the journalShortCode and hash bytes stored in the record.
+func (b *Blockstore) cidFromJournalRecord(shortCode byte, hash []byte) (cid.Cid, error) {
+	e, found := b.opts.MultihashRegistry.lookupShortCode(shortCode)
+	if !found {
+		return cid.Undef, xerrors.Errorf("unknown journal short code %d", shortCode)
+	}
+	mh, err := multihash.Encode(hash, uint64(e.prefix.MhType))
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to encode multihash: %w", err)
+	}
+	return cid.NewCidV1(e.prefix.Codec, mh), nil
+}
+
+// readJournalRecord reads the next self-describing record from a multihash
+// journal: a single journalShortCode byte followed by however many bytes
+// the registry says that short code's hash is. It returns io.EOF once the
+// journal is exhausted, and io.ErrUnexpectedEOF on a partial trailing
+// record (e.g. one still being written).
+func (b *Blockstore) readJournalRecord(r io.Reader) (shortCode byte, hash []byte, err error) {
+	var codeBuf [1]byte
+	if _, err := io.ReadFull(r, codeBuf[:]); err != nil {
+		return 0, nil, err
+	}
+
+	e, found := b.opts.MultihashRegistry.lookupShortCode(codeBuf[0])
+	if !found {
+		return 0, nil, xerrors.Errorf("journal references unknown short code %d", codeBuf[0])
+	}
+
+	hash = make([]byte, e.hashLen)
+	if _, err := io.ReadFull(r, hash); err != nil {
+		return 0, nil, err
+	}
+	return codeBuf[0], hash, nil
+}
+
+// archiveOffsetPath is the sidecar file ArchiveSince persists its progress
+// to, so archival is resumable across restarts.
+func (b *Blockstore) archiveOffsetPath() string {
+	return filepath.Join(b.opts.Dir, mhJournalFilename+".archive-offset")
+}
+
+func (b *Blockstore) persistArchiveOffset(offset int64) error {
+	return os.WriteFile(b.archiveOffsetPath(), []byte(strconv.FormatInt(offset, 10)), 0644)
+}
+
+// LastArchiveOffset returns the journal offset last persisted by
+// ArchiveSince, or 0 if archival has never run.
+func (b *Blockstore) LastArchiveOffset() (int64, error) {
+	data, err := os.ReadFile(b.archiveOffsetPath())
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// ArchiveSince streams every multihash journaled since sinceJournalOffset,
+// fetches the corresponding block from badger, and PUTs it to the
+// configured RemoteArchive keyed by <journalShortCode>/<base32(hash)>. Each
+// successfully archived record's offset is persisted immediately, so a
+// restart resumes from the last checkpoint rather than re-uploading
+// everything. This is also robust to the duplicate records doCopy can
+// produce: re-archiving the same hash is simply an idempotent PUT.
+func (b *Blockstore) ArchiveSince(ctx context.Context, sinceJournalOffset int64) error {
+	if b.opts.RemoteArchive == nil {
+		return fmt.Errorf("no RemoteArchive configured")
+	}
+
+	fh, err := os.Open(filepath.Join(b.opts.Dir, mhJournalFilename))
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	if _, err := fh.Seek(sinceJournalOffset, io.SeekStart); err != nil {
+		return xerrors.Errorf("failed to seek journal to offset %d: %w", sinceJournalOffset, err)
+	}
+
+	offset := sinceJournalOffset
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		shortCode, hash, err := b.readJournalRecord(fh)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// clean end, or a partial trailing record still being written; stop here.
+			break
+		} else if err != nil {
+			return xerrors.Errorf("failed to read journal record at offset %d: %w", offset, err)
+		}
+
+		c, err := b.cidFromJournalRecord(shortCode, hash)
+		if err != nil {
+			return xerrors.Errorf("failed to decode journal record at offset %d: %w", offset, err)
+		}
+
+		blk, err := b.Get(ctx, c)
+		if err != nil {
+			return xerrors.Errorf("failed to read block %s for archival: %w", c, err)
+		}
+
+		if err := b.opts.RemoteArchive.Put(ctx, shortCode, hash, blk.RawData()); err != nil {
+			return xerrors.Errorf("failed to archive block %s: %w", c, err)
+		}
+
+		offset += int64(1 + len(hash))
+		if err := b.persistArchiveOffset(offset); err != nil {
+			return xerrors.Errorf("failed to persist archive offset %d: %w", offset, err)
+		}
+	}
+
+	return nil
+}
+
+// readThroughArchive attempts to fetch c from the configured RemoteArchive,
+// returning (nil, nil) if read-through is disabled, the CID's multihash is
+// not one this blockstore's registry supports, or the archive has no entry.
+func (b *Blockstore) readThroughArchive(ctx context.Context, c cid.Cid) ([]byte, error) {
+	if b.opts.RemoteArchive == nil || !b.opts.ReadThroughArchive {
+		return nil, nil
+	}
+	mh := c.Hash()
+	e, err := b.opts.MultihashRegistry.lookup(mh)
+	if err != nil {
+		return nil, nil
+	}
+	return b.opts.RemoteArchive.Get(ctx, e.journalShortCode, mh[len(mh)-e.hashLen:])
+}
+
+// VerifyOptions configures a Verify run.
+type VerifyOptions struct {
+	// Rehash, if true, re-reads each present block's content and re-hashes
+	// it against the CID reconstructed from the journal, catching silent
+	// corruption that a presence-only check would miss. Substantially more
+	// expensive than the default.
+	Rehash bool
+}
+
+// VerifyReport summarizes a Verify run against the multihash journal.
+type VerifyReport struct {
+	// Journaled is the number of distinct CIDs seen in the journal.
+	Journaled uint64
+	// Present is the number of journaled CIDs found in badger (hot, or cold
+	// if configured) with, if Rehash was set, content matching the CID.
+	Present uint64
+	// Missing is the number of journaled CIDs absent from badger entirely.
+	Missing uint64
+	// Corrupt is the number of journaled CIDs found in badger whose content
+	// did not rehash to the expected CID. Always zero unless Rehash was set.
+	Corrupt uint64
+	// Duplicates is the number of journal records referring to a CID
+	// already accounted for earlier in the journal.
+	Duplicates uint64
+
+	// MissingCids carries every CID counted in Missing, in journal order.
+	// It is closed and fully populated by the time Verify returns.
+	MissingCids <-chan cid.Cid
+}
+
+// Verify streams the multihash journal end to end, reconstructs each
+// journaled CID via Options.MultihashRegistry, and checks it is present in
+// badger. With VerifyOptions.Rehash it additionally re-reads and re-hashes
+// each present block's content, catching corruption a presence check alone
+// would miss.
+//
+// Verify is a read-only diagnostic: it does not repair anything it finds
+// wrong. Use RebuildJournal to regenerate a trustworthy journal from
+// badger's own contents once Verify, or a crash mid-movingGC, casts doubt
+// on the one currently on disk.
+func (b *Blockstore) Verify(ctx context.Context, opts VerifyOptions) (*VerifyReport, error) {
+	if err := b.access(); err != nil {
+		return nil, err
+	}
+	defer b.viewers.Done()
+
+	fh, err := os.Open(filepath.Join(b.opts.Dir, mhJournalFilename))
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open journal for verification: %w", err)
+	}
+	defer fh.Close()
+
+	report := &VerifyReport{}
+	seen := make(map[cid.Cid]struct{})
+	var missing []cid.Cid
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		shortCode, hash, err := b.readJournalRecord(fh)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return nil, xerrors.Errorf("failed to read journal record: %w", err)
+		}
+
+		c, err := b.cidFromJournalRecord(shortCode, hash)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to decode journal record: %w", err)
+		}
+
+		if _, dup := seen[c]; dup {
+			report.Duplicates++
+			continue
+		}
+		seen[c] = struct{}{}
+		report.Journaled++
+
+		// Has, unlike Get, never reads through to the RemoteArchive, so a
+		// block held only there is counted as missing from badger.
+		found, err := b.Has(ctx, c)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to look up journaled block %s: %w", c, err)
+		}
+		if !found {
+			report.Missing++
+			missing = append(missing, c)
+			continue
+		}
+
+		if opts.Rehash {
+			blk, err := b.Get(ctx, c)
+			if err != nil {
+				return nil, xerrors.Errorf("failed to read journaled block %s: %w", c, err)
+			}
+			gotCid, err := c.Prefix().Sum(blk.RawData())
+			if err != nil {
+				return nil, xerrors.Errorf("failed to rehash block %s: %w", c, err)
+			}
+			if !gotCid.Equals(c) {
+				report.Corrupt++
+				continue
+			}
+		}
+
+		report.Present++
+	}
+
+	missingCh := make(chan cid.Cid, len(missing))
+	for _, c := range missing {
+		missingCh <- c
+	}
+	close(missingCh)
+	report.MissingCids = missingCh
+
+	return report, nil
+}
+
+// RebuildJournal regenerates MultiHashes.bin from scratch by streaming every
+// key currently in badger via iterateBadger -- both the hot store and, if
+// configured, the cold store Compact migrates objects into -- deduping
+// entries so the duplicates doCopy and migrateCold can accumulate over the
+// life of a blockstore (see their comments) collapse back down to one
+// record per CID. It is the concrete recovery path for an operator whose
+// Verify, or a crash mid-movingGC, suggests the journal and badger have
+// diverged; omitting the cold store here would make that recovery path the
+// thing that permanently drops every object Compact has ever migrated.
+//
+// RebuildJournal excludes new writes for its duration: like movingGC, it
+// takes the exclusive move lock, so Put/PutMany block until it completes.
+func (b *Blockstore) RebuildJournal(ctx context.Context) error {
+	if err := b.access(); err != nil {
+		return err
+	}
+	defer b.viewers.Done()
+
+	b.lockMove()
+	defer b.unlockMove(moveStateNone)
+
+	tmpPath := filepath.Join(b.opts.Dir, mhJournalFilename+".rebuild")
+	tmpFh, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return xerrors.Errorf("failed to create rebuild journal file: %w", err)
+	}
+
+	var mu sync.Mutex
+	seen := make(map[string]struct{})
+
+	scan := func(db *badger.DB) error {
+		return iterateBadger(ctx, db, func(kvs []*badgerstruct.KV) error {
+			mhBuf := pool.Get(2*varint.MaxLenUvarint63 + b.opts.MultihashRegistry.maxRecordLen())
+			defer pool.Put(mhBuf)
+
+			jrnlSlab := pool.Get(len(kvs) * b.opts.MultihashRegistry.maxRecordLen())
+			defer pool.Put(jrnlSlab)
+			jrnl := jrnlSlab[:0]
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			for _, kv := range kvs {
+				n, err := base32.RawStdEncoding.Decode(mhBuf, kv.Key[b.prefixLen:])
+				if err != nil {
+					return xerrors.Errorf("undecodeable key 0x%X: %w", kv.Key[b.prefixLen:], err)
+				}
+				mh := mhBuf[:n]
+				if _, dup := seen[string(mh)]; dup {
+					continue
+				}
+				seen[string(mh)] = struct{}{}
+
+				e, err := b.opts.MultihashRegistry.lookup(mh)
+				if err != nil {
+					return xerrors.Errorf("unsupported multihash for key 0x%X: %w", kv.Key[b.prefixLen:], err)
+				}
+				jrnl = append(jrnl, e.journalShortCode)
+				jrnl = append(jrnl, mh[len(mh)-e.hashLen:]...)
+			}
+
+			if _, err := tmpFh.Write(jrnl); err != nil {
+				return xerrors.Errorf("failed to write rebuilt journal: %w", err)
+			}
+			return nil
+		})
+	}
+
+	copyErr := scan(b.db)
+	if copyErr == nil && b.cold != nil {
+		copyErr = scan(b.cold)
+	}
+	if copyErr != nil {
+		tmpFh.Close() // nolint:errcheck
+		os.Remove(tmpPath)
+		return xerrors.Errorf("failed to rebuild journal: %w", copyErr)
+	}
+
+	if err := tmpFh.Sync(); err != nil {
+		tmpFh.Close() // nolint:errcheck
+		os.Remove(tmpPath)
+		return xerrors.Errorf("failed to sync rebuilt journal: %w", err)
+	}
+	if err := tmpFh.Close(); err != nil {
+		os.Remove(tmpPath)
+		return xerrors.Errorf("failed to close rebuilt journal: %w", err)
 	}
 
-	err := b.db.Flatten(nworkers)
+	oldJournal := b.mhJournal
+	if err := os.Rename(tmpPath, filepath.Join(b.opts.Dir, mhJournalFilename)); err != nil {
+		return xerrors.Errorf("failed to install rebuilt journal: %w", err)
+	}
+
+	newJournal, err := openJournal(b.opts.Dir)
 	if err != nil {
-		return err
+		return xerrors.Errorf("failed to reopen journal after rebuild: %w", err)
 	}
-	checkTick := time.NewTimer(checkFreq)
-	defer checkTick.Stop()
-	for err == nil {
-		select {
-		case <-ctx.Done():
-			err = ctx.Err()
-		case <-checkTick.C:
-			err = check()
-			checkTick.Reset(checkFreq)
-		default:
-			err = b.db.RunValueLogGC(threshold)
+	b.mhJournal = newJournal
+
+	if oldJournal != nil {
+		if err := oldJournal.Close(); err != nil {
+			log.Warnf("error closing pre-rebuild multihash journal: %s", err)
 		}
 	}
 
-	if err == badger.ErrNoRewrite {
-		// not really an error in this case, it signals the end of GC
-		return nil
+	if err := b.loadMembershipFiltersFrom(b.opts.Dir); err != nil {
+		log.Warnf("error rebuilding membership filters after journal rebuild: %s", err)
 	}
 
-	return err
+	return nil
 }
 
-// CollectGarbage compacts and runs garbage collection on the value log;
-// implements the BlockstoreGC trait
-func (b *Blockstore) CollectGarbage(ctx context.Context, opts ...blockstore.BlockstoreGCOption) error {
+// mhJournalSentinelKey is the synthetic key Backup/Restore use to smuggle
+// a multihash journal through badger's own pb.KVList wire format, alongside
+// the blockstore's data keys. Real storage keys are base32.RawStdEncoding,
+// whose alphabet never emits a 0x00 byte, so this can't collide with one.
+var mhJournalSentinelKey = []byte("\x00mhjournal")
+
+// backupSection tags each record in a Backup stream as belonging to the hot
+// (primary) store or the cold (compacted-into) store, so Restore can route
+// a record's data KVs and journal sentinel to the matching badger instance.
+type backupSection byte
+
+const (
+	backupSectionHot backupSection = iota
+	backupSectionCold
+)
+
+// Backup streams a versioned snapshot of every key under b.prefix (the
+// whole store, if unprefixed) to w using badger's own Stream/pb.KVList
+// framework, followed by a sentinel KV carrying the multihash journal so
+// Restore can rebuild mhJournal without a separate out-of-band copy of
+// MultiHashes.bin. If a cold store is configured (Options.CompactionThreshold),
+// its contents and journal are streamed too, tagged separately, so Restore
+// can reconstitute both tiers -- omitting the cold store here would make
+// Backup silently drop every object Compact has ever migrated out of the
+// hot store. since is a badger version cursor applying only to the hot
+// store: zero backs up everything, and the returned uint64 can be fed back
+// in as since for a later incremental backup; the cold store, expected to
+// change far less often, is always backed up in full.
+func (b *Blockstore) Backup(ctx context.Context, w io.Writer, since uint64) (uint64, error) {
 	if err := b.access(); err != nil {
-		return err
+		return 0, err
 	}
 	defer b.viewers.Done()
 
-	var options blockstore.BlockstoreGCOptions
-	for _, opt := range opts {
-		err := opt(&options)
-		if err != nil {
-			return err
+	b.lockDB()
+	defer b.unlockDB()
+
+	maxVersion, err := b.backupStore(ctx, w, backupSectionHot, b.db, since, b.opts.Dir)
+	if err != nil {
+		return 0, err
+	}
+
+	if b.cold != nil {
+		if _, err := b.backupStore(ctx, w, backupSectionCold, b.cold, 0, b.coldDir()); err != nil {
+			return 0, err
 		}
 	}
 
-	if options.FullGC {
-		return b.movingGC(ctx)
+	return maxVersion, nil
+}
+
+// backupStore streams one badger instance (hot or cold) plus its multihash
+// journal to w, tagged with section, returning the highest version streamed.
+func (b *Blockstore) backupStore(ctx context.Context, w io.Writer, section backupSection, db *badger.DB, since uint64, journalDir string) (uint64, error) {
+	stream := db.NewStream()
+	stream.LogPrefix = "Blockstore.Backup"
+	stream.SinceTs = since
+	if b.prefixing {
+		stream.Prefix = b.prefix
 	}
-	threshold := options.Threshold
-	if threshold == 0 {
-		threshold = defaultGCThreshold
+
+	var maxVersion uint64
+	stream.Send = func(list *badgerstruct.KVList) error {
+		for _, kv := range list.Kv {
+			if kv.Version > maxVersion {
+				maxVersion = kv.Version
+			}
+		}
+		return writeBackupKVList(w, section, list)
 	}
-	checkFreq := options.CheckFreq
-	if checkFreq < 30*time.Second { // disallow checking more frequently than block time
-		checkFreq = 30 * time.Second
+
+	if err := stream.Orchestrate(ctx); err != nil {
+		return 0, xerrors.Errorf("failed to stream blockstore backup: %w", err)
 	}
-	check := options.Check
-	if check == nil {
-		check = func() error {
-			return nil
-		}
+
+	journal, err := os.ReadFile(filepath.Join(journalDir, mhJournalFilename))
+	if err != nil && !os.IsNotExist(err) {
+		return 0, xerrors.Errorf("failed to read multihash journal for backup: %w", err)
 	}
-	return b.onlineGC(ctx, threshold, checkFreq, check)
+	sentinel := &badgerstruct.KVList{Kv: []*badgerstruct.KV{{Key: mhJournalSentinelKey, Value: journal}}}
+	if err := writeBackupKVList(w, section, sentinel); err != nil {
+		return 0, xerrors.Errorf("failed to write journal sentinel to backup: %w", err)
+	}
+
+	return maxVersion, nil
 }
 
-// GCOnce runs garbage collection on the value log;
-// implements BlockstoreGCOnce trait
-func (b *Blockstore) GCOnce(ctx context.Context, opts ...blockstore.BlockstoreGCOption) error {
+// writeBackupKVList appends one section-tagged, length-prefixed, marshaled
+// pb.KVList to a Backup stream, in the same wire format badger's own
+// DB.Backup/Load use, plus a leading section byte so Restore can tell a
+// cold-store record from a hot-store one.
+func writeBackupKVList(w io.Writer, section backupSection, list *badgerstruct.KVList) error {
+	buf, err := list.Marshal()
+	if err != nil {
+		return xerrors.Errorf("failed to marshal backup record: %w", err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, section); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint64(len(buf))); err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// Restore replaces this blockstore's contents wholesale with a snapshot
+// previously written by Backup, rejecting any incoming key whose multihash
+// Options.MultihashRegistry doesn't recognize, and rebuilding mhJournal (and,
+// if the backup carried one, the cold store and its own journal) from the
+// backup's embedded sentinel entries.
+//
+// Restore runs exclusively: it sets moveState to moveStateRestoring for
+// its duration, which -- like a move's moveStateLock -- blocks new
+// Put/View/DeleteMany callers via lockDB until it completes. It is an
+// error to call Restore while a move or another Restore is in progress.
+func (b *Blockstore) Restore(ctx context.Context, r io.Reader) error {
 	if err := b.access(); err != nil {
 		return err
 	}
 	defer b.viewers.Done()
 
-	var options blockstore.BlockstoreGCOptions
-	for _, opt := range opts {
-		err := opt(&options)
-		if err != nil {
-			return err
-		}
+	b.moveMx.Lock()
+	if b.moveState != moveStateNone {
+		b.moveMx.Unlock()
+		return fmt.Errorf("move or restore already in progress")
 	}
-	if options.FullGC {
-		return xerrors.Errorf("FullGC option specified for GCOnce but full GC is non incremental")
+	b.moveState = moveStateRestoring
+	for b.rlock > 0 {
+		b.moveCond.Wait()
 	}
+	b.moveMx.Unlock()
+	defer func() {
+		// unlockMove expects moveMx held, and it was released above.
+		b.moveMx.Lock()
+		b.unlockMove(moveStateNone)
+	}()
 
-	threshold := options.Threshold
-	if threshold == 0 {
-		threshold = defaultGCThreshold
-	}
+	br := bufio.NewReaderSize(r, 16<<10)
+	unmarshalBuf := make([]byte, 1<<20)
 
-	b.lockDB()
-	defer b.unlockDB()
+	var journal, coldJournalBytes []byte
+	var sawCold bool
+	hotBatch := b.db.NewWriteBatch()
+	defer hotBatch.Cancel()
+	var coldBatch *badger.WriteBatch
 
-	// Note no compaction needed before single GC as we will hit at most one vlog anyway
-	err := b.db.RunValueLogGC(threshold)
-	if err == badger.ErrNoRewrite {
-		// not really an error in this case, it signals the end of GC
-		return nil
-	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-	return err
-}
+		var section backupSection
+		if err := binary.Read(br, binary.LittleEndian, &section); err == io.EOF {
+			break
+		} else if err != nil {
+			return xerrors.Errorf("failed to read backup record section: %w", err)
+		}
 
-// Size returns the aggregate size of the blockstore
-func (b *Blockstore) Size() (int64, error) {
-	var size int64
+		var sz uint64
+		if err := binary.Read(br, binary.LittleEndian, &sz); err != nil {
+			return xerrors.Errorf("failed to read backup record length: %w", err)
+		}
 
-	// do not use b.db.Size(): since we are storing data outside of usual
-	// badger files it can't be accurate anyway. Just sum up the dir sizes
-	// without even trying to lock the db
-	//
-	// moreover: badger reports a 0 size on symlinked directories anyway
-	dir := b.opts.Dir
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return 0, err
+		if uint64(cap(unmarshalBuf)) < sz {
+			unmarshalBuf = make([]byte, sz)
+		}
+		buf := unmarshalBuf[:sz]
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return xerrors.Errorf("failed to read backup record: %w", err)
+		}
+
+		list := &badgerstruct.KVList{}
+		if err := list.Unmarshal(buf); err != nil {
+			return xerrors.Errorf("failed to unmarshal backup record: %w", err)
+		}
+
+		batch := hotBatch
+		if section == backupSectionCold {
+			if !sawCold {
+				if err := b.openCold(); err != nil {
+					return xerrors.Errorf("failed to open cold store for restore: %w", err)
+				}
+				coldBatch = b.cold.NewWriteBatch()
+				defer coldBatch.Cancel()
+				sawCold = true
+			}
+			batch = coldBatch
+		}
+
+		for _, kv := range list.Kv {
+			if bytes.Equal(kv.Key, mhJournalSentinelKey) {
+				if section == backupSectionCold {
+					coldJournalBytes = kv.Value
+				} else {
+					journal = kv.Value
+				}
+				continue
+			}
+
+			if len(kv.Key) <= b.prefixLen {
+				return xerrors.Errorf("undersized backup key 0x%X", kv.Key)
+			}
+			mh, err := base32.RawStdEncoding.DecodeString(string(kv.Key[b.prefixLen:]))
+			if err != nil {
+				return xerrors.Errorf("undecodeable backup key 0x%X: %w", kv.Key, err)
+			}
+			if _, err := b.opts.MultihashRegistry.lookup(mh); err != nil {
+				return xerrors.Errorf("rejecting unsupported multihash in backup key 0x%X: %w", kv.Key, err)
+			}
+
+			entry := badger.NewEntry(append([]byte{}, kv.Key...), append([]byte{}, kv.Value...))
+			if err := batch.SetEntry(entry); err != nil {
+				return xerrors.Errorf("failed to stage restored key 0x%X: %w", kv.Key, err)
+			}
+		}
 	}
 
-	for _, e := range entries {
-		path := filepath.Join(dir, e.Name())
-		finfo, _ := os.Stat(path) // ignore potential error: if we are in a compaction an .sst might disappear on us
-		size += finfo.Size()
+	if err := hotBatch.Flush(); err != nil {
+		return xerrors.Errorf("failed to flush restored batch: %w", err)
+	}
+	if coldBatch != nil {
+		if err := coldBatch.Flush(); err != nil {
+			return xerrors.Errorf("failed to flush restored cold batch: %w", err)
+		}
 	}
 
-	return size, nil
-}
+	if journal != nil {
+		oldJournal := b.mhJournal
+		if err := os.WriteFile(filepath.Join(b.opts.Dir, mhJournalFilename), journal, 0644); err != nil {
+			return xerrors.Errorf("failed to restore multihash journal: %w", err)
+		}
+		newJournal, err := openJournal(b.opts.Dir)
+		if err != nil {
+			return xerrors.Errorf("failed to reopen restored multihash journal: %w", err)
+		}
+		b.mhJournal = newJournal
 
-func isMultihashSupported(mh []byte) (supportedMultihash, error) {
-	var smh supportedMultihash
-	mhDec, err := multihash.Decode(mh)
-	if err != nil {
-		return smh, xerrors.Errorf("unexpected error decoding multihash 0x%X: %s", mh, err)
+		if oldJournal != nil {
+			if err := oldJournal.Close(); err != nil {
+				log.Warnf("error closing pre-restore multihash journal: %s", err)
+			}
+		}
 	}
-	if mhDec.Length != supportedHashLen {
-		return smh, xerrors.Errorf("unsupported hash length of %d bits", mhDec.Length*8)
+
+	if coldJournalBytes != nil {
+		oldColdJournal := b.coldJournal
+		if err := os.WriteFile(filepath.Join(b.coldDir(), mhJournalFilename), coldJournalBytes, 0644); err != nil {
+			return xerrors.Errorf("failed to restore cold multihash journal: %w", err)
+		}
+		newColdJournal, err := openJournal(b.coldDir())
+		if err != nil {
+			return xerrors.Errorf("failed to reopen restored cold multihash journal: %w", err)
+		}
+		b.coldJournal = newColdJournal
+
+		if oldColdJournal != nil {
+			if err := oldColdJournal.Close(); err != nil {
+				log.Warnf("error closing pre-restore cold multihash journal: %s", err)
+			}
+		}
 	}
-	smh, found := supportedMultihashes[string(mh[:len(mh)-supportedHashLen])]
-	if !found {
-		return smh, xerrors.Errorf("unsupported multihash prefix 0x%X", mh[:len(mh)-supportedHashLen])
+
+	if journal != nil {
+		// filters are warmed from the restored hot journal alone. Put
+		// records every multihash there and migrateCold never removes one,
+		// but a MoveTo rewrites it from the hot store's contents only, so
+		// it can lack cold-resident objects; Get/Has/View/GetSize all
+		// still fall back to the cold store on a filter miss.
+		if err := b.loadMembershipFiltersFrom(b.opts.Dir); err != nil {
+			log.Warnf("error rebuilding membership filters after restore: %s", err)
+		}
 	}
-	return smh, nil
+
+	return nil
 }
 
 // badgerGet is a basic tri-state:  value+nil  nil+nil  nil+err
@@ -786,15 +2410,46 @@ func (b *Blockstore) View(ctx context.Context, c cid.Cid, fn func([]byte) error)
 		defer KeyPool.Put(k)
 	}
 
-	return b.db.View(func(txn *badger.Txn) error {
-		val, err := badgerGet(txn, k)
-		if err != nil {
-			return fmt.Errorf("failed to view block from badger blockstore: %w", err)
-		} else if val == nil {
-			return ipld.ErrNotFound{Cid: c}
+	mh := c.Hash()
+	filterMiss := false
+	if e, serr := b.opts.MultihashRegistry.lookup(mh); serr == nil {
+		filterMiss = !b.getFilter(e.journalShortCode).mayContain(mh[len(mh)-e.hashLen:])
+	}
+
+	var err error
+	if filterMiss {
+		err = ipld.ErrNotFound{Cid: c}
+	} else {
+		err = b.db.View(func(txn *badger.Txn) error {
+			val, err := badgerGet(txn, k)
+			if err != nil {
+				return fmt.Errorf("failed to view block from badger blockstore: %w", err)
+			} else if val == nil {
+				return ipld.ErrNotFound{Cid: c}
+			}
+			return val.badgerItem.Value(fn)
+		})
+	}
+
+	if _, ok := err.(ipld.ErrNotFound); ok && b.cold != nil {
+		err = b.cold.View(func(txn *badger.Txn) error {
+			val, err := badgerGet(txn, k)
+			if err != nil {
+				return fmt.Errorf("failed to view block from cold badger blockstore: %w", err)
+			} else if val == nil {
+				return ipld.ErrNotFound{Cid: c}
+			}
+			return val.badgerItem.Value(fn)
+		})
+	}
+
+	if _, ok := err.(ipld.ErrNotFound); ok {
+		if data, aerr := b.readThroughArchive(ctx, c); aerr == nil && data != nil {
+			return fn(data)
 		}
-		return val.badgerItem.Value(fn)
-	})
+	}
+
+	return err
 }
 
 func (b *Blockstore) Flush(context.Context) error {
@@ -838,6 +2493,15 @@ func (b *Blockstore) Has(ctx context.Context, c cid.Cid) (bool, error) {
 	b.lockDB()
 	defer b.unlockDB()
 
+	// a filter miss only rules out the hot store; with a cold store
+	// configured, fall through so the cold lookup below still runs.
+	mh := c.Hash()
+	if e, err := b.opts.MultihashRegistry.lookup(mh); err == nil && b.cold == nil {
+		if !b.getFilter(e.journalShortCode).mayContain(mh[len(mh)-e.hashLen:]) {
+			return false, nil
+		}
+	}
+
 	k, pooled := b.PooledStorageKey(c)
 	if pooled {
 		defer KeyPool.Put(k)
@@ -855,6 +2519,20 @@ func (b *Blockstore) Has(ctx context.Context, c cid.Cid) (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("failed to check if block exists in badger blockstore: %w", err)
 	}
+
+	if !canHaz && b.cold != nil {
+		err := b.cold.View(func(txn *badger.Txn) error {
+			val, err := badgerGet(txn, k)
+			if val != nil {
+				canHaz = true
+			}
+			return err
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to check if block exists in cold badger blockstore: %w", err)
+		}
+	}
+
 	return canHaz, nil
 }
 
@@ -878,17 +2556,49 @@ func (b *Blockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
 	}
 
 	var buf []byte
+	var err error
 
-	if err := b.db.View(func(txn *badger.Txn) error {
-		val, err := badgerGet(txn, k)
-		if err != nil {
-			return fmt.Errorf("failed to get block from badger blockstore: %w", err)
-		} else if val == nil {
-			return ipld.ErrNotFound{Cid: c}
+	mh := c.Hash()
+	filterMiss := false
+	if e, serr := b.opts.MultihashRegistry.lookup(mh); serr == nil {
+		filterMiss = !b.getFilter(e.journalShortCode).mayContain(mh[len(mh)-e.hashLen:])
+	}
+
+	if filterMiss {
+		err = ipld.ErrNotFound{Cid: c}
+	} else {
+		err = b.db.View(func(txn *badger.Txn) error {
+			val, err := badgerGet(txn, k)
+			if err != nil {
+				return fmt.Errorf("failed to get block from badger blockstore: %w", err)
+			} else if val == nil {
+				return ipld.ErrNotFound{Cid: c}
+			}
+			buf, err = val.badgerItem.ValueCopy(nil)
+			return err
+		})
+	}
+
+	if _, ok := err.(ipld.ErrNotFound); ok && b.cold != nil {
+		err = b.cold.View(func(txn *badger.Txn) error {
+			val, err := badgerGet(txn, k)
+			if err != nil {
+				return fmt.Errorf("failed to get block from cold badger blockstore: %w", err)
+			} else if val == nil {
+				return ipld.ErrNotFound{Cid: c}
+			}
+			buf, err = val.badgerItem.ValueCopy(nil)
+			return err
+		})
+	}
+
+	if _, ok := err.(ipld.ErrNotFound); ok {
+		if data, aerr := b.readThroughArchive(ctx, c); aerr == nil && data != nil {
+			return blocks.NewBlockWithCid(data, c)
 		}
-		buf, err = val.badgerItem.ValueCopy(nil)
-		return err
-	}); err != nil {
+	}
+
+	if err != nil {
 		return nil, err
 	}
 
@@ -910,19 +2620,47 @@ func (b *Blockstore) GetSize(ctx context.Context, c cid.Cid) (int, error) {
 		defer KeyPool.Put(k)
 	}
 
+	mh := c.Hash()
+	filterMiss := false
+	if e, serr := b.opts.MultihashRegistry.lookup(mh); serr == nil {
+		filterMiss = !b.getFilter(e.journalShortCode).mayContain(mh[len(mh)-e.hashLen:])
+	}
+
 	size := -1
-	err := b.db.View(func(txn *badger.Txn) error {
-		val, err := badgerGet(txn, k)
+	var err error
+	if filterMiss {
+		err = ipld.ErrNotFound{Cid: c}
+	} else {
+		err = b.db.View(func(txn *badger.Txn) error {
+			val, err := badgerGet(txn, k)
 
-		if err != nil {
-			return fmt.Errorf("failed to get block size from badger blockstore: %w", err)
-		} else if val == nil {
-			return ipld.ErrNotFound{Cid: c}
-		}
+			if err != nil {
+				return fmt.Errorf("failed to get block size from badger blockstore: %w", err)
+			} else if val == nil {
+				return ipld.ErrNotFound{Cid: c}
+			}
 
-		size = int(val.badgerItem.ValueSize())
-		return nil
-	})
+			size = int(val.badgerItem.ValueSize())
+			return nil
+		})
+	}
+
+	// the filter is sharded by hot-tier journalShortCode, so a filter miss
+	// (or plain absence from the hot db) doesn't rule out the cold tier --
+	// mirror Get/Has/View's fallback so a block migrateCold moved to cold
+	// doesn't start reporting ErrNotFound from GetSize alone.
+	if _, ok := err.(ipld.ErrNotFound); ok && b.cold != nil {
+		err = b.cold.View(func(txn *badger.Txn) error {
+			val, err := badgerGet(txn, k)
+			if err != nil {
+				return fmt.Errorf("failed to get block size from cold badger blockstore: %w", err)
+			} else if val == nil {
+				return ipld.ErrNotFound{Cid: c}
+			}
+			size = int(val.badgerItem.ValueSize())
+			return nil
+		})
+	}
 
 	return size, err
 }
@@ -964,7 +2702,7 @@ func (b *Blockstore) PutMany(ctx context.Context, blocks []blocks.Block) error {
 		keys[i] = k
 	}
 
-	jrnlSlab := pool.Get(len(blocks) * mhJournalRecordLen)
+	jrnlSlab := pool.Get(len(blocks) * b.opts.MultihashRegistry.maxRecordLen())
 	defer pool.Put(jrnlSlab)
 	jrnl := jrnlSlab[:0]
 
@@ -980,14 +2718,17 @@ func (b *Blockstore) PutMany(ctx context.Context, blocks []blocks.Block) error {
 			} else {
 				// Got to insert that, check it is supported, write journal
 				mh := blocks[i].Cid().Hash()
-				smh, err := isMultihashSupported(mh)
+				e, err := b.opts.MultihashRegistry.lookup(mh)
 				if err != nil {
 					return xerrors.Errorf("unsupported multihash for cid %s: %w", blocks[i].Cid(), err)
 				}
 
 				// add a journal record
-				jrnl = append(jrnl, smh.journalShortCode)
-				jrnl = append(jrnl, mh[len(mh)-supportedHashLen:]...)
+				jrnl = append(jrnl, e.journalShortCode)
+				jrnl = append(jrnl, mh[len(mh)-e.hashLen:]...)
+
+				// the filter must be updated in lockstep with the journal append.
+				b.getFilter(e.journalShortCode).add(mh[len(mh)-e.hashLen:], b.opts.MembershipFilterMaxUnique, b.filterFPRate())
 			}
 		}
 		return nil
@@ -0,0 +1,605 @@
+package badgerbs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/dgraph-io/badger/v2"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-base32"
+	"github.com/multiformats/go-multihash"
+	"github.com/multiformats/go-varint"
+	"golang.org/x/xerrors"
+)
+
+// carV2Pragma is the fixed 11-byte CARv2 pragma: a varint-prefixed
+// DAG-CBOR {"version":2}.
+var carV2Pragma = []byte{0x0a, 0xa1, 0x67, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x02}
+
+// carV2HeaderLen is the size of the fixed-width CARv2 header that follows
+// the pragma: 16 bytes of characteristics, then three little-endian uint64
+// offsets (data offset, data size, index offset).
+const carV2HeaderLen = 16 + 8 + 8 + 8
+
+// carIndexMagic tags the index section written by ExportCAR. This
+// package's index is its own lightweight (multihash, offset) codec --
+// mirroring the self-rolled binary format of the multihash journal --
+// rather than the standard go-car/v2 IndexSorted codec, consistent with
+// this package staying free of IPLD-schema/go-car dependencies.
+var carIndexMagic = [8]byte{'b', 'a', 'd', 'g', 'e', 'r', 'i', 'x'}
+
+// ExportCAR streams blocks to w as a CARv2 file: pragma, fixed header,
+// CARv1 data section (a DAG-CBOR header recording roots, followed by
+// varint-length-prefixed (CID, data) frames), and a trailing index section
+// mapping each block's multihash to its frame offset.
+//
+// When selector is nil, every block currently in the hot badger instance is
+// exported, by iterating it directly and reusing the same base32-decode
+// buffer AllKeysChan uses to avoid redecoding each key twice; objects
+// Compact has migrated to the cold store are not exported. When selector is
+// non-nil, ExportCAR instead walks outward from roots: selector is handed
+// each visited block's CID and raw bytes and returns the CIDs to visit
+// next, playing the role of an IPLD LinkSystem without this package taking
+// on an IPLD-schema dependency -- the same shallow-by-default posture as
+// markReachable, just driven by a caller-supplied link extractor instead
+// of treating every root as a leaf.
+//
+// ExportCAR buffers the data section in a scratch file under Options.Dir
+// so it can backfill the CARv2 header's DataSize/IndexOffset fields
+// without requiring a seekable w.
+func (b *Blockstore) ExportCAR(ctx context.Context, w io.Writer, roots []cid.Cid, selector func(cid.Cid, []byte) ([]cid.Cid, error)) error {
+	if err := b.access(); err != nil {
+		return err
+	}
+	defer b.viewers.Done()
+
+	tmp, err := os.CreateTemp(b.opts.Dir, "car-export-*.tmp")
+	if err != nil {
+		return xerrors.Errorf("failed to create scratch file for CAR export: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // nolint:errcheck
+	defer tmp.Close()           // nolint:errcheck
+
+	if err := writeCarV1Header(tmp, roots); err != nil {
+		return xerrors.Errorf("failed to write CAR data header: %w", err)
+	}
+
+	idx := make([]carIndexEntry, 0, 64)
+	emit := func(c cid.Cid, data []byte) error {
+		off, err := tmp.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		if err := writeCarFrame(tmp, c, data); err != nil {
+			return err
+		}
+		idx = append(idx, carIndexEntry{mh: append([]byte{}, c.Hash()...), offset: uint64(off)})
+		return nil
+	}
+
+	if selector != nil {
+		if err := b.walkSelectedForCAR(ctx, roots, selector, emit); err != nil {
+			return err
+		}
+	} else if err := b.exportAllBlocksForCAR(ctx, emit); err != nil {
+		return err
+	}
+
+	dataSize, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return xerrors.Errorf("failed to measure CAR data section: %w", err)
+	}
+
+	dataOffset := uint64(len(carV2Pragma) + carV2HeaderLen)
+	if _, err := w.Write(carV2Pragma); err != nil {
+		return err
+	}
+	if err := writeCarV2Header(w, dataOffset, uint64(dataSize), dataOffset+uint64(dataSize)); err != nil {
+		return xerrors.Errorf("failed to write CARv2 header: %w", err)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return xerrors.Errorf("failed to rewind CAR scratch file: %w", err)
+	}
+	if _, err := io.Copy(w, tmp); err != nil {
+		return xerrors.Errorf("failed to copy CAR data section: %w", err)
+	}
+
+	if err := writeCarIndex(w, idx); err != nil {
+		return xerrors.Errorf("failed to write CAR index: %w", err)
+	}
+
+	return nil
+}
+
+// exportAllBlocksForCAR iterates every key currently in the hot badger
+// instance (b.db; the cold store is not read), in the same style as
+// AllKeysChan, but reads the value alongside the key in a single iterator
+// pass instead of a second round-trip through Get.
+//
+// Like ForEachKey and Backup, it holds lockDB/unlockDB for its
+// duration: ExportCAR's own access() bumps viewers, not rlock, and a
+// concurrent MoveTo/movingGC only waits on rlock before swapping the
+// underlying *badger.DB, so without this a no-selector export could read
+// from a closed/swapped db mid-iteration.
+func (b *Blockstore) exportAllBlocksForCAR(ctx context.Context, emit func(cid.Cid, []byte) error) error {
+	b.lockDB()
+	defer b.unlockDB()
+
+	txn := b.db.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.IteratorOptions{PrefetchSize: 100, PrefetchValues: true}
+	if b.prefixing {
+		opts.Prefix = b.prefix
+	}
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+
+	var buf []byte
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !b.isOpen() {
+			return ErrBlockstoreClosed
+		}
+
+		item := iter.Item()
+		k := item.Key()
+		if b.prefixing {
+			k = k[b.prefixLen:]
+		}
+
+		if reqlen := base32.RawStdEncoding.DecodedLen(len(k)); len(buf) < reqlen {
+			buf = make([]byte, reqlen)
+		}
+		n, err := base32.RawStdEncoding.Decode(buf, k)
+		if err != nil {
+			log.Warnf("failed to decode key %s while exporting CAR; err: %s", k, err)
+			continue
+		}
+
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return xerrors.Errorf("failed to read value while exporting CAR: %w", err)
+		}
+
+		mh := append([]byte{}, buf[:n]...)
+		e, err := b.opts.MultihashRegistry.lookup(mh)
+		if err != nil {
+			return xerrors.Errorf("unsupported multihash for key 0x%X: %w", k, err)
+		}
+
+		if err := emit(cid.NewCidV1(e.prefix.Codec, multihash.Multihash(mh)), val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkSelectedForCAR performs a breadth-first walk from roots, fetching
+// each block via Get and following selector's reported children, emitting
+// every block exactly once.
+func (b *Blockstore) walkSelectedForCAR(ctx context.Context, roots []cid.Cid, selector func(cid.Cid, []byte) ([]cid.Cid, error), emit func(cid.Cid, []byte) error) error {
+	seen := make(map[cid.Cid]struct{}, len(roots))
+	queue := append([]cid.Cid{}, roots...)
+
+	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		c := queue[0]
+		queue = queue[1:]
+
+		if _, dup := seen[c]; dup {
+			continue
+		}
+		seen[c] = struct{}{}
+
+		blk, err := b.Get(ctx, c)
+		if err != nil {
+			return xerrors.Errorf("failed to fetch %s while exporting CAR: %w", c, err)
+		}
+
+		if err := emit(c, blk.RawData()); err != nil {
+			return err
+		}
+
+		children, err := selector(c, blk.RawData())
+		if err != nil {
+			return xerrors.Errorf("selector failed for %s: %w", c, err)
+		}
+		for _, child := range children {
+			if _, dup := seen[child]; !dup {
+				queue = append(queue, child)
+			}
+		}
+	}
+	return nil
+}
+
+// ImportCAR reads a CARv2 file written by ExportCAR -- pragma, header, and
+// CARv1 data section; the trailing index, if any, is ignored, since roots
+// and blocks are all Import needs -- and writes every block it contains
+// via PutMany, in batches, so restored blocks flow through the exact same
+// NewWriteBatch/journal path a normal Put does and are rejected the same
+// way an unsupported multihash would be. It returns the roots recorded in
+// the CARv1 header.
+func (b *Blockstore) ImportCAR(ctx context.Context, r io.Reader) ([]cid.Cid, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+
+	if err := readCarV2Pragma(br); err != nil {
+		return nil, err
+	}
+	_, dataSize, _, err := readCarV2Header(br)
+	if err != nil {
+		return nil, err
+	}
+
+	dbr := bufio.NewReaderSize(io.LimitReader(br, int64(dataSize)), 1<<20)
+
+	roots, err := readCarV1Header(dbr)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read CAR data header: %w", err)
+	}
+
+	const importBatchSize = 256
+	batch := make([]blocks.Block, 0, importBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := b.PutMany(ctx, batch); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		c, data, err := readCarFrame(dbr)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, xerrors.Errorf("failed to read CAR frame: %w", err)
+		}
+
+		if _, err := b.opts.MultihashRegistry.lookup(c.Hash()); err != nil {
+			return nil, xerrors.Errorf("rejecting unsupported multihash in CAR block %s: %w", c, err)
+		}
+
+		blk, err := blocks.NewBlockWithCid(data, c)
+		if err != nil {
+			return nil, xerrors.Errorf("CAR block %s failed hash verification: %w", c, err)
+		}
+
+		batch = append(batch, blk)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return roots, nil
+}
+
+// writeCarFrame writes one CARv1 block frame: a varint byte length,
+// followed by the CID and the raw block data it describes.
+func writeCarFrame(w io.Writer, c cid.Cid, data []byte) error {
+	cidBytes := c.Bytes()
+	frameLen := uint64(len(cidBytes) + len(data))
+
+	var lenBuf [varint.MaxLenUvarint63]byte
+	n := varint.PutUvarint(lenBuf[:], frameLen)
+	if _, err := w.Write(lenBuf[:n]); err != nil {
+		return err
+	}
+	if _, err := w.Write(cidBytes); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// readCarFrame reads one CARv1 block frame written by writeCarFrame.
+func readCarFrame(br *bufio.Reader) (cid.Cid, []byte, error) {
+	frameLen, err := varint.ReadUvarint(br)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+
+	buf := make([]byte, frameLen)
+	if _, err := io.ReadFull(br, buf); err != nil {
+		return cid.Undef, nil, err
+	}
+
+	n, c, err := cid.CidFromBytes(buf)
+	if err != nil {
+		return cid.Undef, nil, xerrors.Errorf("failed to parse CID from CAR frame: %w", err)
+	}
+	return c, buf[n:], nil
+}
+
+// writeCarV1Header writes the CARv1 header CAR readers expect at the start
+// of the data section: a varint-prefixed DAG-CBOR map with exactly two
+// keys, "version" (always 1) and "roots". The encoding is hand-rolled for
+// this one fixed shape rather than pulling in a DAG-CBOR codec dependency.
+func writeCarV1Header(w io.Writer, roots []cid.Cid) error {
+	var body bytes.Buffer
+	body.WriteByte(0xa2) // map, 2 entries
+
+	writeCborTextString(&body, "version")
+	body.WriteByte(0x01) // uint 1
+
+	writeCborTextString(&body, "roots")
+	writeCborHeader(&body, 4, uint64(len(roots)))
+	for _, c := range roots {
+		writeCborHeader(&body, 6, 42) // tag 42: the DAG-CBOR CID tag
+		cb := c.Bytes()
+		writeCborHeader(&body, 2, uint64(len(cb)+1)) // +1 for the multibase-identity prefix byte
+		body.WriteByte(0x00)
+		body.Write(cb)
+	}
+
+	var lenBuf [varint.MaxLenUvarint63]byte
+	n := varint.PutUvarint(lenBuf[:], uint64(body.Len()))
+	if _, err := w.Write(lenBuf[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// readCarV1Header reads the header writeCarV1Header writes. It is a strict
+// reader for this package's own fixed encoding, not a general CBOR parser.
+func readCarV1Header(br *bufio.Reader) ([]cid.Cid, error) {
+	hlen, err := varint.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, hlen)
+	if _, err := io.ReadFull(br, body); err != nil {
+		return nil, err
+	}
+
+	r := bytes.NewReader(body)
+	if b, err := r.ReadByte(); err != nil || b != 0xa2 {
+		return nil, xerrors.Errorf("unsupported CAR header: expected a 2-entry CBOR map")
+	}
+
+	if key, err := readCborTextString(r); err != nil {
+		return nil, err
+	} else if key != "version" {
+		return nil, xerrors.Errorf("unsupported CAR header: expected \"version\" first, got %q", key)
+	}
+	if _, err := r.ReadByte(); err != nil { // version value: a single-byte uint for version 1
+		return nil, err
+	}
+
+	key, err := readCborTextString(r)
+	if err != nil {
+		return nil, err
+	}
+	if key != "roots" {
+		return nil, xerrors.Errorf("unsupported CAR header: expected \"roots\" after \"version\", got %q", key)
+	}
+
+	major, n, err := readCborHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if major != 4 {
+		return nil, xerrors.Errorf("unsupported CAR header: \"roots\" is not a CBOR array")
+	}
+
+	roots := make([]cid.Cid, 0, n)
+	for i := uint64(0); i < n; i++ {
+		tagMajor, tag, err := readCborHeader(r)
+		if err != nil {
+			return nil, err
+		}
+		if tagMajor != 6 || tag != 42 {
+			return nil, xerrors.Errorf("unsupported CAR header: root is not a tag-42 CID")
+		}
+
+		bsMajor, blen, err := readCborHeader(r)
+		if err != nil {
+			return nil, err
+		}
+		if bsMajor != 2 {
+			return nil, xerrors.Errorf("unsupported CAR header: CID is not a byte string")
+		}
+
+		buf := make([]byte, blen)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if len(buf) == 0 || buf[0] != 0x00 {
+			return nil, xerrors.Errorf("unsupported CAR header: CID missing multibase-identity prefix")
+		}
+
+		c, err := cid.Cast(buf[1:])
+		if err != nil {
+			return nil, xerrors.Errorf("failed to parse root CID: %w", err)
+		}
+		roots = append(roots, c)
+	}
+	return roots, nil
+}
+
+// writeCborHeader writes a CBOR major-type/length header for a value of up
+// to 2^64-1, the only shapes this package's fixed CAR header needs.
+func writeCborHeader(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major<<5 | byte(n))
+	case n <= 0xff:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(major<<5 | 25)
+		var b [2]byte
+		binary.BigEndian.PutUint16(b[:], uint16(n))
+		buf.Write(b[:])
+	case n <= 0xffffffff:
+		buf.WriteByte(major<<5 | 26)
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(n))
+		buf.Write(b[:])
+	default:
+		buf.WriteByte(major<<5 | 27)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], n)
+		buf.Write(b[:])
+	}
+}
+
+func writeCborTextString(buf *bytes.Buffer, s string) {
+	writeCborHeader(buf, 3, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+// readCborHeader is the counterpart to writeCborHeader.
+func readCborHeader(r *bytes.Reader) (major byte, n uint64, err error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	major = b >> 5
+	info := b & 0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		v, err := r.ReadByte()
+		return major, uint64(v), err
+	case info == 25:
+		var buf [2]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return 0, 0, err
+		}
+		return major, uint64(binary.BigEndian.Uint16(buf[:])), nil
+	case info == 26:
+		var buf [4]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return 0, 0, err
+		}
+		return major, uint64(binary.BigEndian.Uint32(buf[:])), nil
+	case info == 27:
+		var buf [8]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return 0, 0, err
+		}
+		return major, binary.BigEndian.Uint64(buf[:]), nil
+	default:
+		return 0, 0, xerrors.Errorf("unsupported CBOR additional info %d", info)
+	}
+}
+
+func readCborTextString(r *bytes.Reader) (string, error) {
+	major, n, err := readCborHeader(r)
+	if err != nil {
+		return "", err
+	}
+	if major != 3 {
+		return "", xerrors.Errorf("expected CBOR text string, got major type %d", major)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func writeCarV2Header(w io.Writer, dataOffset, dataSize, indexOffset uint64) error {
+	var buf [carV2HeaderLen]byte
+	// characteristics (buf[0:16]) intentionally left zero: this package's
+	// index section isn't the standard go-car/v2 IndexSorted codec, so it
+	// doesn't claim the "fully indexed" characteristic bit for it.
+	binary.LittleEndian.PutUint64(buf[16:24], dataOffset)
+	binary.LittleEndian.PutUint64(buf[24:32], dataSize)
+	binary.LittleEndian.PutUint64(buf[32:40], indexOffset)
+	_, err := w.Write(buf[:])
+	return err
+}
+
+func readCarV2Pragma(br *bufio.Reader) error {
+	buf := make([]byte, len(carV2Pragma))
+	if _, err := io.ReadFull(br, buf); err != nil {
+		return xerrors.Errorf("failed to read CAR pragma: %w", err)
+	}
+	if !bytes.Equal(buf, carV2Pragma) {
+		return xerrors.Errorf("not a CARv2 file: unexpected pragma")
+	}
+	return nil
+}
+
+func readCarV2Header(br *bufio.Reader) (dataOffset, dataSize, indexOffset uint64, err error) {
+	buf := make([]byte, carV2HeaderLen)
+	if _, err := io.ReadFull(br, buf); err != nil {
+		return 0, 0, 0, xerrors.Errorf("failed to read CARv2 header: %w", err)
+	}
+	dataOffset = binary.LittleEndian.Uint64(buf[16:24])
+	dataSize = binary.LittleEndian.Uint64(buf[24:32])
+	indexOffset = binary.LittleEndian.Uint64(buf[32:40])
+	return dataOffset, dataSize, indexOffset, nil
+}
+
+// carIndexEntry is one (multihash, frame offset) pair in the index section
+// ExportCAR writes; offsets are relative to the start of the CARv1 data
+// section (i.e. the CARv2 header's DataOffset).
+type carIndexEntry struct {
+	mh     []byte
+	offset uint64
+}
+
+// writeCarIndex writes entries, sorted by multihash, prefixed with
+// carIndexMagic and a uint64 count.
+func writeCarIndex(w io.Writer, entries []carIndexEntry) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].mh, entries[j].mh) < 0
+	})
+
+	if _, err := w.Write(carIndexMagic[:]); err != nil {
+		return err
+	}
+	var countBuf [8]byte
+	binary.LittleEndian.PutUint64(countBuf[:], uint64(len(entries)))
+	if _, err := w.Write(countBuf[:]); err != nil {
+		return err
+	}
+
+	var lenBuf [varint.MaxLenUvarint63]byte
+	for _, e := range entries {
+		n := varint.PutUvarint(lenBuf[:], uint64(len(e.mh)))
+		if _, err := w.Write(lenBuf[:n]); err != nil {
+			return err
+		}
+		if _, err := w.Write(e.mh); err != nil {
+			return err
+		}
+		var offBuf [8]byte
+		binary.LittleEndian.PutUint64(offBuf[:], e.offset)
+		if _, err := w.Write(offBuf[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package badgerbs
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multicodec"
+)
+
+// TestExportAllBlocksForCARUsesRegistryCodec guards against
+// exportAllBlocksForCAR hardcoding cid.Raw for the whole-store export path:
+// the codec it wraps each emitted CID in must come from the configured
+// MultihashRegistry, the same as doCopy/migrateCold/RebuildJournal/ImportCAR
+// already do, not a codec that merely happens to match the default registry.
+func TestExportAllBlocksForCARUsesRegistryCodec(t *testing.T) {
+	ctx := context.Background()
+
+	reg := NewMultihashRegistry()
+	if err := reg.Register(cid.NewPrefixV1(uint64(multicodec.DagCbor), uint64(multicodec.Sha2_256)), 0, legacyHashLen); err != nil {
+		t.Fatalf("Register: %s", err)
+	}
+
+	opts := DefaultOptions(t.TempDir())
+	opts.MultihashRegistry = reg
+	bs, err := Open(opts)
+	if err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := bs.Close(); err != nil {
+			t.Errorf("Close: %s", err)
+		}
+	})
+
+	blk := blocks.NewBlock([]byte("a block"))
+	if err := bs.Put(ctx, blk); err != nil {
+		t.Fatalf("Put: %s", err)
+	}
+
+	var got []cid.Cid
+	emit := func(c cid.Cid, data []byte) error {
+		got = append(got, c)
+		return nil
+	}
+	if err := bs.exportAllBlocksForCAR(ctx, emit); err != nil {
+		t.Fatalf("exportAllBlocksForCAR: %s", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("exportAllBlocksForCAR emitted %d blocks, want 1", len(got))
+	}
+	if got[0].Prefix().Codec != uint64(multicodec.DagCbor) {
+		t.Fatalf("emitted CID codec = %#x, want DagCbor (%#x): exportAllBlocksForCAR is not using the registry's codec", got[0].Prefix().Codec, uint64(multicodec.DagCbor))
+	}
+	if !got[0].Hash().Equal(blk.Cid().Hash()) {
+		t.Fatalf("emitted CID hash = %x, want %x", got[0].Hash(), blk.Cid().Hash())
+	}
+}
+
+// TestExportAllBlocksForCARRejectsUnsupportedMultihash checks that a key
+// whose multihash isn't in the configured registry fails the export instead
+// of silently being wrapped as cid.Raw.
+func TestExportAllBlocksForCARRejectsUnsupportedMultihash(t *testing.T) {
+	ctx := context.Background()
+
+	bs := newTestBlockstore(t, 0)
+	blk := blocks.NewBlock([]byte("a block"))
+	if err := bs.Put(ctx, blk); err != nil {
+		t.Fatalf("Put: %s", err)
+	}
+
+	// Swap in a registry that doesn't know about the multihash type
+	// already stored, simulating a misconfigured reopen.
+	reg := NewMultihashRegistry()
+	if err := reg.Register(cid.NewPrefixV1(uint64(multicodec.DagCbor), uint64(multicodec.Blake2b256)), 1, legacyHashLen); err != nil {
+		t.Fatalf("Register: %s", err)
+	}
+	bs.opts.MultihashRegistry = reg
+
+	err := bs.exportAllBlocksForCAR(ctx, func(cid.Cid, []byte) error { return nil })
+	if err == nil {
+		t.Fatalf("exportAllBlocksForCAR succeeded with an unsupported multihash in the store, want an error")
+	}
+}
@@ -0,0 +1,52 @@
+package badgerbs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+)
+
+// TestExportImportCARRoundTrip is a basic sanity check for the CARv2
+// codec: every block written to a store should come back, unharmed, via
+// ExportCAR with no selector (the whole-store path exportAllBlocksForCAR
+// implements) followed by ImportCAR into a fresh store.
+func TestExportImportCARRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	src := newTestBlockstore(t, 0)
+	blk1 := blocks.NewBlock([]byte("block one"))
+	blk2 := blocks.NewBlock([]byte("block two"))
+	if err := src.Put(ctx, blk1); err != nil {
+		t.Fatalf("Put: %s", err)
+	}
+	if err := src.Put(ctx, blk2); err != nil {
+		t.Fatalf("Put: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.ExportCAR(ctx, &buf, []cid.Cid{blk1.Cid()}, nil); err != nil {
+		t.Fatalf("ExportCAR: %s", err)
+	}
+
+	dst := newTestBlockstore(t, 0)
+	roots, err := dst.ImportCAR(ctx, &buf)
+	if err != nil {
+		t.Fatalf("ImportCAR: %s", err)
+	}
+	if len(roots) != 1 || !roots[0].Equals(blk1.Cid()) {
+		t.Fatalf("roots = %v, want [%s]", roots, blk1.Cid())
+	}
+
+	for _, blk := range []blocks.Block{blk1, blk2} {
+		got, err := dst.Get(ctx, blk.Cid())
+		if err != nil {
+			t.Fatalf("Get(%s): %s", blk.Cid(), err)
+		}
+		if !bytes.Equal(got.RawData(), blk.RawData()) {
+			t.Fatalf("Get(%s) = %q, want %q", blk.Cid(), got.RawData(), blk.RawData())
+		}
+	}
+}
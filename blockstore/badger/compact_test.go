@@ -0,0 +1,210 @@
+package badgerbs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+)
+
+// inHotStore reports whether k is currently present in bs's hot db.
+func inHotStore(t *testing.T, bs *Blockstore, k []byte) bool {
+	t.Helper()
+	found := false
+	if err := bs.db.View(func(txn *badger.Txn) error {
+		val, err := badgerGet(txn, k)
+		if err != nil {
+			return err
+		}
+		found = val != nil
+		return nil
+	}); err != nil {
+		t.Fatalf("checking hot store: %s", err)
+	}
+	return found
+}
+
+// inColdStore reports whether k is currently present in bs's cold db.
+func inColdStore(t *testing.T, bs *Blockstore, k []byte) bool {
+	t.Helper()
+	found := false
+	if err := bs.cold.View(func(txn *badger.Txn) error {
+		val, err := badgerGet(txn, k)
+		if err != nil {
+			return err
+		}
+		found = val != nil
+		return nil
+	}); err != nil {
+		t.Fatalf("checking cold store: %s", err)
+	}
+	return found
+}
+
+// TestMarkReachableFallsBackToCold guards against markReachable dead-ending
+// its BFS on a node that an earlier Compact already migrated to cold: once
+// that happens, the node's still-reachable children must still be found via
+// the cold store, not silently dropped from hot (and then wrongly swept to
+// cold themselves by the very same migrateCold call).
+func TestMarkReachableFallsBackToCold(t *testing.T) {
+	ctx := context.Background()
+	bs := newTestBlockstore(t, 1)
+
+	child := blocks.NewBlock([]byte("child"))
+	if err := bs.Put(ctx, child); err != nil {
+		t.Fatalf("Put child: %s", err)
+	}
+	parent := blocks.NewBlock(child.Cid().Bytes())
+	if err := bs.Put(ctx, parent); err != nil {
+		t.Fatalf("Put parent: %s", err)
+	}
+
+	bs.opts.LinkExtractor = func(c cid.Cid, data []byte) ([]cid.Cid, error) {
+		linked, err := cid.Cast(data)
+		if err != nil {
+			return nil, nil // leaf: not every block's bytes are a CID
+		}
+		return []cid.Cid{linked}, nil
+	}
+
+	// Simulate an earlier Compact that only kept parent hot, migrating
+	// child to cold.
+	parentKey, pooled := bs.PooledStorageKey(parent.Cid())
+	if pooled {
+		defer KeyPool.Put(parentKey)
+	}
+	onlyParentHot := map[string]struct{}{string(parentKey): {}}
+	if err := bs.migrateCold(ctx, onlyParentHot); err != nil {
+		t.Fatalf("migrateCold: %s", err)
+	}
+
+	// Now walk from parent again, as a later Compact would after a reorg
+	// re-references it. child is no longer hot, so without the cold
+	// fallback this walk would stop at parent and never learn about child.
+	hot := map[string]struct{}{}
+	if err := bs.markReachable(parent.Cid(), hot); err != nil {
+		t.Fatalf("markReachable: %s", err)
+	}
+
+	childKey, pooled := bs.PooledStorageKey(child.Cid())
+	if pooled {
+		defer KeyPool.Put(childKey)
+	}
+
+	if _, ok := hot[string(parentKey)]; !ok {
+		t.Fatalf("markReachable did not mark parent hot")
+	}
+	if _, ok := hot[string(childKey)]; !ok {
+		t.Fatalf("markReachable did not mark cold-resident child hot: cold fallback not working")
+	}
+}
+
+// TestCompactRespectsThreshold checks Compact is a no-op until
+// currentEpoch-lastCompactEpoch reaches CompactionThreshold, per its doc
+// comment, and advances lastCompactEpoch only once it actually runs.
+func TestCompactRespectsThreshold(t *testing.T) {
+	ctx := context.Background()
+	bs := newTestBlockstore(t, 5) // CompactionThreshold: 5, CompactionBoundary: 1
+
+	calls := 0
+	getTipset := func(epoch int64) []cid.Cid {
+		calls++
+		return nil
+	}
+
+	if err := bs.Compact(ctx, 4, getTipset); err != nil {
+		t.Fatalf("Compact: %s", err)
+	}
+	if calls != 0 {
+		t.Fatalf("Compact called getTipset %d times before CompactionThreshold elapsed, want 0", calls)
+	}
+	if bs.lastCompactEpoch != 0 {
+		t.Fatalf("lastCompactEpoch = %d, want 0 (no-op Compact must not advance it)", bs.lastCompactEpoch)
+	}
+
+	if err := bs.Compact(ctx, 5, getTipset); err != nil {
+		t.Fatalf("Compact: %s", err)
+	}
+	if calls == 0 {
+		t.Fatalf("Compact did not call getTipset once CompactionThreshold elapsed")
+	}
+	if bs.lastCompactEpoch != 5 {
+		t.Fatalf("lastCompactEpoch = %d, want 5", bs.lastCompactEpoch)
+	}
+}
+
+// TestCompactWalksBoundaryWindow checks Compact calls getTipset for every
+// epoch in [currentEpoch-CompactionBoundary, currentEpoch], inclusive on
+// both ends, and nothing outside it.
+func TestCompactWalksBoundaryWindow(t *testing.T) {
+	ctx := context.Background()
+	bs := newTestBlockstore(t, 1)
+	bs.opts.CompactionBoundary = 3
+
+	var epochs []int64
+	getTipset := func(epoch int64) []cid.Cid {
+		epochs = append(epochs, epoch)
+		return nil
+	}
+
+	if err := bs.Compact(ctx, 10, getTipset); err != nil {
+		t.Fatalf("Compact: %s", err)
+	}
+
+	want := []int64{7, 8, 9, 10}
+	if len(epochs) != len(want) {
+		t.Fatalf("Compact visited epochs %v, want %v", epochs, want)
+	}
+	for i, e := range want {
+		if epochs[i] != e {
+			t.Fatalf("Compact visited epochs %v, want %v", epochs, want)
+		}
+	}
+}
+
+// TestCompactMigratesUnreferencedBlocks is an end-to-end check that Compact
+// keeps a referenced block hot and migrates an unreferenced one to cold.
+func TestCompactMigratesUnreferencedBlocks(t *testing.T) {
+	ctx := context.Background()
+	bs := newTestBlockstore(t, 1)
+
+	live := blocks.NewBlock([]byte("live"))
+	dead := blocks.NewBlock([]byte("dead"))
+	if err := bs.Put(ctx, live); err != nil {
+		t.Fatalf("Put live: %s", err)
+	}
+	if err := bs.Put(ctx, dead); err != nil {
+		t.Fatalf("Put dead: %s", err)
+	}
+
+	getTipset := func(epoch int64) []cid.Cid {
+		if epoch == 10 {
+			return []cid.Cid{live.Cid()}
+		}
+		return nil
+	}
+	if err := bs.Compact(ctx, 10, getTipset); err != nil {
+		t.Fatalf("Compact: %s", err)
+	}
+
+	liveKey, pooled := bs.PooledStorageKey(live.Cid())
+	if pooled {
+		defer KeyPool.Put(liveKey)
+	}
+	deadKey, pooled := bs.PooledStorageKey(dead.Cid())
+	if pooled {
+		defer KeyPool.Put(deadKey)
+	}
+
+	if !inHotStore(t, bs, liveKey) {
+		t.Fatalf("referenced block was migrated out of the hot store")
+	}
+	if inHotStore(t, bs, deadKey) {
+		t.Fatalf("unreferenced block was left in the hot store")
+	}
+	if !inColdStore(t, bs, deadKey) {
+		t.Fatalf("unreferenced block was not migrated to the cold store")
+	}
+}
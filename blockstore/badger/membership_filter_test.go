@@ -0,0 +1,20 @@
+package badgerbs
+
+import "testing"
+
+// TestMembershipFilterKeyClampsToShortHash guards against the panic a
+// MultihashRegistry entry with hashLen shorter than
+// Options.MembershipFilterPrefixBytes used to trigger on the first
+// Put/Get/Has/View of that multihash type.
+func TestMembershipFilterKeyClampsToShortHash(t *testing.T) {
+	f := newMembershipFilter(4, "", 0)
+
+	short := []byte{0xaa, 0xbb}
+	f.add(short, 0, 0)
+	if !f.mayContain(short) {
+		t.Fatalf("mayContain(%x) = false, want true after add", short)
+	}
+	if f.mayContain([]byte{0xaa, 0xcc}) {
+		t.Fatalf("mayContain unexpectedly true for a hash never added")
+	}
+}
@@ -0,0 +1,68 @@
+package badgerbs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+)
+
+// TestMoveToAppliesFilter checks MoveTo's pruning contract: a block filter
+// rejects is dropped from the relocated store, and one it accepts survives,
+// readable exactly as before the move.
+func TestMoveToAppliesFilter(t *testing.T) {
+	ctx := context.Background()
+	bs := newTestBlockstore(t, 0)
+
+	keep := blocks.NewBlock([]byte("keep me"))
+	drop := blocks.NewBlock([]byte("drop me"))
+	if err := bs.Put(ctx, keep); err != nil {
+		t.Fatalf("Put keep: %s", err)
+	}
+	if err := bs.Put(ctx, drop); err != nil {
+		t.Fatalf("Put drop: %s", err)
+	}
+
+	filter := func(c cid.Cid) bool { return c.Equals(keep.Cid()) }
+	newPath := filepath.Join(t.TempDir(), "moved")
+	if err := bs.MoveTo(ctx, newPath, filter); err != nil {
+		t.Fatalf("MoveTo: %s", err)
+	}
+
+	if found, err := bs.Has(ctx, keep.Cid()); err != nil || !found {
+		t.Fatalf("Has(keep) = %v, %v; want true, nil", found, err)
+	}
+	if found, err := bs.Has(ctx, drop.Cid()); err != nil || found {
+		t.Fatalf("Has(drop) = %v, %v; want false, nil (filter should have pruned it)", found, err)
+	}
+}
+
+// TestMoveToNilFilterKeepsEverything checks MoveTo with a nil filter -- the
+// path movingGC's unfiltered relocation uses -- copies every block across
+// untouched.
+func TestMoveToNilFilterKeepsEverything(t *testing.T) {
+	ctx := context.Background()
+	bs := newTestBlockstore(t, 0)
+
+	blk1 := blocks.NewBlock([]byte("block one"))
+	blk2 := blocks.NewBlock([]byte("block two"))
+	if err := bs.Put(ctx, blk1); err != nil {
+		t.Fatalf("Put blk1: %s", err)
+	}
+	if err := bs.Put(ctx, blk2); err != nil {
+		t.Fatalf("Put blk2: %s", err)
+	}
+
+	newPath := filepath.Join(t.TempDir(), "moved")
+	if err := bs.MoveTo(ctx, newPath, nil); err != nil {
+		t.Fatalf("MoveTo: %s", err)
+	}
+
+	for _, blk := range []blocks.Block{blk1, blk2} {
+		if found, err := bs.Has(ctx, blk.Cid()); err != nil || !found {
+			t.Fatalf("Has(%s) = %v, %v; want true, nil", blk.Cid(), found, err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package badgerbs
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math/bits"
+	"math/rand"
+	"sync"
+)
+
+const (
+	cuckooBucketSize = 4
+	cuckooMaxKicks   = 500
+	cuckooMinBuckets = 16
+	cuckooLoadFactor = 0.9 // target load before resorting to kicks
+)
+
+// cuckooFilter is the cuckoo-filter alternative to a bloom filter for
+// membershipFilter's probabilistic phase (Options.NegativeCacheType ==
+// "cuckoo"). It stores a single-byte fingerprint of each key in one of two
+// candidate buckets, which -- unlike a bloom filter -- makes deletion
+// possible in principle, though membershipFilter never deletes (the
+// journal it's built from is append-only).
+//
+// A cuckoo filter has a capacity unbloom filters don't: once a bucket pair
+// is full, inserting a new key can require evicting and relocating an
+// existing fingerprint, and that relocation can fail. Losing a fingerprint
+// outright would turn a live block invisible to mayContain, which is
+// unsafe (the caller would wrongly treat an existing block as absent), so
+// a failed insert instead flips degraded permanently: every mayContain on
+// a degraded filter returns true, falling back to a real badger lookup for
+// that journalShortCode exactly as if it had no filter at all.
+type cuckooFilter struct {
+	mu sync.RWMutex
+
+	buckets    [][cuckooBucketSize]uint8
+	numBuckets uint64
+
+	degraded bool
+	count    uint64
+}
+
+// newCuckooFilter sizes a table for capacity entries at the standard ~90%
+// load factor, rounding the bucket count up to a power of two so index2
+// can be computed with a cheap XOR.
+func newCuckooFilter(capacity uint64) *cuckooFilter {
+	if capacity == 0 {
+		capacity = 1
+	}
+	numBuckets := nextPowerOfTwo(uint64(float64(capacity)/cuckooLoadFactor/cuckooBucketSize) + 1)
+	if numBuckets < cuckooMinBuckets {
+		numBuckets = cuckooMinBuckets
+	}
+	return &cuckooFilter{
+		buckets:    make([][cuckooBucketSize]uint8, numBuckets),
+		numBuckets: numBuckets,
+	}
+}
+
+func nextPowerOfTwo(n uint64) uint64 {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << uint(64-bits.LeadingZeros64(n-1))
+}
+
+// cuckooFingerprint derives a non-zero one-byte fingerprint for key; 0 is
+// reserved to mean "empty slot".
+func cuckooFingerprint(key uint32) uint8 {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], key)
+	h := fnv.New32a()
+	h.Write(buf[:]) // nolint:errcheck
+	fp := uint8(h.Sum32())
+	if fp == 0 {
+		fp = 1
+	}
+	return fp
+}
+
+func (f *cuckooFilter) index1(key uint32) uint64 {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], key)
+	h := fnv.New64a()
+	h.Write(buf[:]) // nolint:errcheck
+	return h.Sum64() % f.numBuckets
+}
+
+// index2 derives the alternate bucket from fp so that, given either index
+// and the fingerprint, the other index is recoverable by XOR alone --
+// the standard partial-key cuckoo hashing trick.
+func (f *cuckooFilter) index2(i1 uint64, fp uint8) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte{fp}) // nolint:errcheck
+	return (i1 ^ h.Sum64()) % f.numBuckets
+}
+
+func (f *cuckooFilter) insertInto(bucket uint64, fp uint8) bool {
+	b := &f.buckets[bucket]
+	for i, slot := range b {
+		if slot == 0 {
+			b[i] = fp
+			return true
+		}
+	}
+	return false
+}
+
+// add inserts key's fingerprint, kicking existing fingerprints between
+// their two candidate buckets if both are already full. If no free slot
+// is found within cuckooMaxKicks, the filter is marked degraded rather
+// than dropping the fingerprint silently.
+func (f *cuckooFilter) add(key uint32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.degraded {
+		return
+	}
+
+	fp := cuckooFingerprint(key)
+	i1 := f.index1(key)
+	i2 := f.index2(i1, fp)
+
+	if f.insertInto(i1, fp) || f.insertInto(i2, fp) {
+		f.count++
+		return
+	}
+
+	i := i1
+	if rand.Intn(2) == 1 { // nolint:gosec
+		i = i2
+	}
+	for kick := 0; kick < cuckooMaxKicks; kick++ {
+		slot := rand.Intn(cuckooBucketSize) // nolint:gosec
+		evicted := f.buckets[i][slot]
+		f.buckets[i][slot] = fp
+		fp = evicted
+		i = f.index2(i, fp)
+
+		if f.insertInto(i, fp) {
+			f.count++
+			return
+		}
+	}
+
+	f.degraded = true
+}
+
+// mayContain reports whether key's fingerprint is present in either
+// candidate bucket, or unconditionally true once the filter is degraded.
+func (f *cuckooFilter) mayContain(key uint32) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.degraded {
+		return true
+	}
+
+	fp := cuckooFingerprint(key)
+	i1 := f.index1(key)
+	i2 := f.index2(i1, fp)
+
+	for _, slot := range f.buckets[i1] {
+		if slot == fp {
+			return true
+		}
+	}
+	for _, slot := range f.buckets[i2] {
+		if slot == fp {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package badgerbs
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+)
+
+// TestLiveFilterSwitchPreservesReads is an end-to-end check that a real
+// Put-driven switch from the exact membership filter to the probabilistic
+// cuckoo cache (Options.NegativeCacheType == "cuckoo") doesn't cost any
+// already-stored block its Get/Has visibility, and that a block never put
+// is still correctly reported absent once the filter is running in
+// probabilistic mode.
+func TestLiveFilterSwitchPreservesReads(t *testing.T) {
+	ctx := context.Background()
+
+	opts := DefaultOptions(t.TempDir())
+	opts.NegativeCacheType = "cuckoo"
+	opts.MembershipFilterMaxUnique = 2 // force a switch well before this test's block count
+
+	bs, err := Open(opts)
+	if err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := bs.Close(); err != nil {
+			t.Errorf("Close: %s", err)
+		}
+	})
+
+	var put []blocks.Block
+	for i := 0; i < 8; i++ {
+		blk := blocks.NewBlock([]byte{byte(i)})
+		if err := bs.Put(ctx, blk); err != nil {
+			t.Fatalf("Put: %s", err)
+		}
+		put = append(put, blk)
+	}
+
+	stats := bs.BloomStats()
+	if len(stats) != 1 || !stats[0].Probabilistic {
+		t.Fatalf("BloomStats() = %+v, want exactly one probabilistic entry after exceeding MembershipFilterMaxUnique", stats)
+	}
+
+	for _, blk := range put {
+		found, err := bs.Has(ctx, blk.Cid())
+		if err != nil {
+			t.Fatalf("Has(%s): %s", blk.Cid(), err)
+		}
+		if !found {
+			t.Fatalf("Has(%s) = false after the live switch to probabilistic mode, want true", blk.Cid())
+		}
+		got, err := bs.Get(ctx, blk.Cid())
+		if err != nil {
+			t.Fatalf("Get(%s): %s", blk.Cid(), err)
+		}
+		if string(got.RawData()) != string(blk.RawData()) {
+			t.Fatalf("Get(%s) = %q, want %q", blk.Cid(), got.RawData(), blk.RawData())
+		}
+	}
+
+	neverPut := blocks.NewBlock([]byte("never put"))
+	if found, err := bs.Has(ctx, neverPut.Cid()); err != nil || found {
+		t.Fatalf("Has(never put) = %v, %v; want false, nil", found, err)
+	}
+}
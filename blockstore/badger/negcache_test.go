@@ -0,0 +1,130 @@
+package badgerbs
+
+import "testing"
+
+// TestCuckooFilterAddAndMayContain is a basic sanity check: every key added
+// must be reported present, and an unrelated key should (almost always)
+// not be.
+func TestCuckooFilterAddAndMayContain(t *testing.T) {
+	f := newCuckooFilter(64)
+
+	for i := uint32(0); i < 32; i++ {
+		f.add(i)
+	}
+	for i := uint32(0); i < 32; i++ {
+		if !f.mayContain(i) {
+			t.Fatalf("mayContain(%d) = false after add", i)
+		}
+	}
+	if f.mayContain(12345) {
+		t.Fatalf("mayContain(12345) = true for a key never added (non-degraded filter)")
+	}
+	if f.degraded {
+		t.Fatalf("filter degraded after only 32 inserts into a 64-capacity table")
+	}
+}
+
+// TestCuckooFilterDegradesInsteadOfDroppingAKey guards the safety property
+// cuckooFilter's doc comment calls out: once insertion genuinely can't find
+// a free slot, the filter must flip degraded (mayContain always true) rather
+// than silently lose a fingerprint, which would make a live block wrongly
+// look absent.
+func TestCuckooFilterDegradesInsteadOfDroppingAKey(t *testing.T) {
+	f := newCuckooFilter(1) // rounds up to cuckooMinBuckets, but still tiny capacity
+
+	// Drive it well past its load factor; cuckooMaxKicks should eventually
+	// be exhausted and trip degraded.
+	for i := uint32(0); i < uint32(f.numBuckets)*cuckooBucketSize*4; i++ {
+		f.add(i)
+	}
+
+	if !f.degraded {
+		t.Fatalf("filter not degraded after gross overfill; test no longer exercises the degraded path")
+	}
+	if !f.mayContain(0xdeadbeef) {
+		t.Fatalf("mayContain() = false on a degraded filter, want true (degraded means unconditionally true)")
+	}
+}
+
+// TestMembershipFilterExactMode checks the exact (roaring bitmap) phase
+// before any probabilistic switch: every added hash is reported present,
+// and one never added is not.
+func TestMembershipFilterExactMode(t *testing.T) {
+	f := newMembershipFilter(4, "", 0)
+
+	hashes := [][]byte{{0x01, 0x02, 0x03, 0x04}, {0xaa, 0xbb, 0xcc, 0xdd}, {0x10, 0x20, 0x30, 0x40}}
+	for _, h := range hashes {
+		f.add(h, 0, 0) // maxUnique == 0: unbounded, never switches (see Options.MembershipFilterMaxUnique)
+	}
+	for _, h := range hashes {
+		if !f.mayContain(h) {
+			t.Fatalf("mayContain(%x) = false after add", h)
+		}
+	}
+	if f.mayContain([]byte{0xff, 0xff, 0xff, 0xff}) {
+		t.Fatalf("mayContain unexpectedly true for a hash never added")
+	}
+	if f.probabilistic {
+		t.Fatalf("filter switched to probabilistic with maxUnique == 0")
+	}
+
+	stats := f.stats(0)
+	if stats.UniqueCount != uint64(len(hashes)) || stats.Probabilistic {
+		t.Fatalf("stats() = %+v, want UniqueCount=%d Probabilistic=false", stats, len(hashes))
+	}
+}
+
+// TestMembershipFilterSwitchesToBloom checks add flips to the probabilistic
+// bloom phase once maxUnique is exceeded, and that membership survives the
+// switch (no false negatives on already-added hashes).
+func TestMembershipFilterSwitchesToBloom(t *testing.T) {
+	f := newMembershipFilter(4, "bloom", 0)
+
+	const maxUnique = 4
+	var added [][]byte
+	for i := byte(0); i < maxUnique+2; i++ {
+		h := []byte{0, 0, 0, i}
+		added = append(added, h)
+		f.add(h, maxUnique, 0.01)
+	}
+
+	if !f.probabilistic {
+		t.Fatalf("filter did not switch to probabilistic after exceeding maxUnique=%d", maxUnique)
+	}
+	for _, h := range added {
+		if !f.mayContain(h) {
+			t.Fatalf("mayContain(%x) = false for a hash added before the switch to bloom", h)
+		}
+	}
+
+	stats := f.stats(0)
+	if !stats.Probabilistic {
+		t.Fatalf("stats().Probabilistic = false after switching to bloom")
+	}
+}
+
+// TestMembershipFilterSwitchesToCuckoo is TestMembershipFilterSwitchesToBloom's
+// counterpart for Options.NegativeCacheType == "cuckoo".
+func TestMembershipFilterSwitchesToCuckoo(t *testing.T) {
+	f := newMembershipFilter(4, "cuckoo", 0)
+
+	const maxUnique = 4
+	var added [][]byte
+	for i := byte(0); i < maxUnique+2; i++ {
+		h := []byte{0, 0, 0, i}
+		added = append(added, h)
+		f.add(h, maxUnique, 0.01)
+	}
+
+	if !f.probabilistic {
+		t.Fatalf("filter did not switch to probabilistic after exceeding maxUnique=%d", maxUnique)
+	}
+	if f.cuckoo == nil {
+		t.Fatalf("negCacheKind=cuckoo switched without allocating a cuckooFilter")
+	}
+	for _, h := range added {
+		if !f.mayContain(h) {
+			t.Fatalf("mayContain(%x) = false for a hash added before the switch to cuckoo", h)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package badgerbs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+)
+
+// TestRebuildJournalIncludesColdStore guards against RebuildJournal
+// permanently dropping any object Compact has migrated to the cold store:
+// deleting the (corrupt) hot journal and rebuilding it must still account
+// for a cold-resident block, not just hot-resident ones.
+func TestRebuildJournalIncludesColdStore(t *testing.T) {
+	ctx := context.Background()
+
+	bs := newTestBlockstore(t, 1)
+
+	hotBlk := blocks.NewBlock([]byte("hot block"))
+	if err := bs.Put(ctx, hotBlk); err != nil {
+		t.Fatalf("Put hot block: %s", err)
+	}
+	coldBlk := blocks.NewBlock([]byte("cold block"))
+	if err := bs.Put(ctx, coldBlk); err != nil {
+		t.Fatalf("Put cold-to-be block: %s", err)
+	}
+
+	hot := map[string]struct{}{}
+	k, _ := bs.PooledStorageKey(hotBlk.Cid())
+	hot[string(k)] = struct{}{}
+	if err := bs.migrateCold(ctx, hot); err != nil {
+		t.Fatalf("migrateCold: %s", err)
+	}
+
+	// simulate a diverged/corrupt journal, same as an operator recovering
+	// from a crash would find.
+	if err := os.Truncate(filepath.Join(bs.opts.Dir, mhJournalFilename), 0); err != nil {
+		t.Fatalf("truncating journal: %s", err)
+	}
+
+	if err := bs.RebuildJournal(ctx); err != nil {
+		t.Fatalf("RebuildJournal: %s", err)
+	}
+
+	report, err := bs.Verify(ctx, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify: %s", err)
+	}
+	if report.Journaled != 2 {
+		t.Fatalf("report.Journaled = %d, want 2 (rebuilt journal dropped the cold-store entry)", report.Journaled)
+	}
+	if report.Missing != 0 {
+		t.Fatalf("report.Missing = %d, want 0", report.Missing)
+	}
+}
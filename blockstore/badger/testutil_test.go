@@ -0,0 +1,26 @@
+package badgerbs
+
+import "testing"
+
+// newTestBlockstore opens a Blockstore rooted in a fresh t.TempDir(), closed
+// automatically at test cleanup. compactionThreshold > 0 makes Open eagerly
+// open the cold store (see Options.CompactionThreshold), which several
+// tests need in order to exercise cold-store code paths directly.
+func newTestBlockstore(t *testing.T, compactionThreshold int64) *Blockstore {
+	t.Helper()
+
+	opts := DefaultOptions(t.TempDir())
+	opts.CompactionThreshold = compactionThreshold
+	opts.CompactionBoundary = 1
+
+	bs, err := Open(opts)
+	if err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := bs.Close(); err != nil {
+			t.Errorf("Close: %s", err)
+		}
+	})
+	return bs
+}
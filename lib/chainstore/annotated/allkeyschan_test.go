@@ -0,0 +1,37 @@
+package annotated
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestErrInitiallyNil checks Err reports no error before any AllKeysChan
+// scan has run.
+//
+// AllKeysChan itself opens a real server-side Postgres cursor and isn't
+// exercised here: doing so needs a live dbPool, which this package's unit
+// tests have nowhere to get (there's no in-memory/fake pgxpool.Pool to
+// substitute). Err is the one piece of AllKeysChan's bookkeeping that is
+// self-contained enough to check without one.
+func TestErrInitiallyNil(t *testing.T) {
+	cs := newTestACS(t)
+	if err := cs.Err(); err != nil {
+		t.Fatalf("Err() = %v, want nil before any scan has run", err)
+	}
+}
+
+// TestErrReflectsMostRecentScan checks Err reads back whatever the most
+// recently completed scan recorded, guarded by the same mutex AllKeysChan's
+// goroutine writes it under.
+func TestErrReflectsMostRecentScan(t *testing.T) {
+	cs := newTestACS(t)
+
+	wantErr := errors.New("scan failed")
+	cs.mu.Lock()
+	cs.allKeysErr = wantErr
+	cs.mu.Unlock()
+
+	if err := cs.Err(); err != wantErr {
+		t.Fatalf("Err() = %v, want %v", err, wantErr)
+	}
+}
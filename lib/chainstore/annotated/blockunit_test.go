@@ -0,0 +1,72 @@
+package annotated
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestBlockUnitViewRefcount guards against the race where one concurrent
+// View(c) caller's release() truncated and returned viewBuf to the pool
+// while another caller sharing the same cache-resident *blockUnit was
+// still reading it: the buffer must only go back to the pool once every
+// concurrent reader has released it.
+func TestBlockUnitViewRefcount(t *testing.T) {
+	buf := []byte("hello world")
+	bu := &blockUnit{viewBuf: &buf}
+
+	const readers = 8
+	start := make(chan struct{})
+	var wg, acquired sync.WaitGroup
+	acquired.Add(readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			got := bu.acquireView()
+			// every reader holds the view before any of them releases it
+			acquired.Done()
+			acquired.Wait()
+			if string(got) != "hello world" {
+				t.Errorf("acquireView() = %q, want %q", got, "hello world")
+			}
+			bu.release()
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	bu.mu.Lock()
+	defer bu.mu.Unlock()
+	if bu.viewBuf != nil {
+		t.Fatalf("viewBuf not released back to the pool after all readers finished")
+	}
+	if bu.viewRefs != 0 {
+		t.Fatalf("viewRefs = %d, want 0", bu.viewRefs)
+	}
+}
+
+// TestBlockUnitViewRefcountOutlivesEarlyRelease checks that a released
+// reader doesn't tear down viewBuf out from under a slower, still-reading
+// sibling call sharing the same blockUnit.
+func TestBlockUnitViewRefcountOutlivesEarlyRelease(t *testing.T) {
+	buf := []byte("still here")
+	bu := &blockUnit{viewBuf: &buf}
+
+	fast := bu.acquireView()
+	slow := bu.acquireView()
+
+	bu.release() // fast caller finishes first
+
+	if string(slow) != "still here" {
+		t.Fatalf("slow reader's view corrupted after sibling release: got %q", slow)
+	}
+	bu.mu.Lock()
+	if bu.viewBuf == nil {
+		t.Fatalf("viewBuf released to the pool while a reader was still using it")
+	}
+	bu.mu.Unlock()
+
+	_ = fast
+	bu.release()
+}
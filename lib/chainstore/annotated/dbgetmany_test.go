@@ -0,0 +1,109 @@
+package annotated
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/ristretto"
+	ipfsblock "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+)
+
+// newTestACS returns an *acs with just enough wired up to exercise
+// dbGetMany/HasMany's cache path -- dbPool is deliberately left nil, so a
+// cid the cache doesn't hold is never queried and comes back as a miss.
+func newTestACS(t *testing.T) *acs {
+	t.Helper()
+
+	cache, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: 1e4,
+		MaxCost:     1 << 20,
+		BufferItems: 64,
+	})
+	if err != nil {
+		t.Fatalf("ristretto.NewCache: %s", err)
+	}
+
+	return &acs{
+		cache:             cache,
+		accessStatsRecent: make(map[uint64]struct{}),
+		accessStatsHiRes:  make(map[accessUnit]uint64),
+	}
+}
+
+// TestDbGetManyCacheHits checks dbGetMany (HasMany's shared batched path)
+// returns every cache-resident unit without touching dbPool, which is nil
+// here and would panic if dialed.
+func TestDbGetManyCacheHits(t *testing.T) {
+	cs := newTestACS(t)
+
+	blk1 := ipfsblock.NewBlock([]byte("block one"))
+	dbID1 := uint64(1)
+
+	bu1 := &blockUnit{cid: blk1.Cid(), dbID: &dbID1, size: uint32(len(blk1.RawData()))}
+	cs.cache.Set(blk1.Cid(), bu1, int64(bu1.size))
+	cs.cache.Wait()
+
+	missing := ipfsblock.NewBlock([]byte("never put")).Cid()
+
+	bus, err := cs.dbGetMany([]cid.Cid{blk1.Cid(), missing}, HAS)
+	if err != nil {
+		t.Fatalf("dbGetMany: %s", err)
+	}
+	if bus[0] != bu1 {
+		t.Fatalf("dbGetMany()[0] = %v, want the cached unit", bus[0])
+	}
+	if bus[1] != nil {
+		t.Fatalf("dbGetMany()[1] = %v, want nil for an entirely unknown cid with no dbPool to query", bus[1])
+	}
+}
+
+// TestHasManyCacheHits checks HasMany's found/missing mapping on top of
+// dbGetMany's cache path: one cache hit and one unknown cid.
+func TestHasManyCacheHits(t *testing.T) {
+	cs := newTestACS(t)
+
+	present := ipfsblock.NewBlock([]byte("present"))
+	dbID := uint64(7)
+	bu := &blockUnit{cid: present.Cid(), dbID: &dbID, size: uint32(len(present.RawData()))}
+	cs.cache.Set(present.Cid(), bu, int64(bu.size))
+	cs.cache.Wait()
+
+	missing := ipfsblock.NewBlock([]byte("absent")).Cid()
+
+	found, err := cs.HasMany([]cid.Cid{present.Cid(), missing})
+	if err != nil {
+		t.Fatalf("HasMany: %s", err)
+	}
+	if !found[0] || found[1] {
+		t.Fatalf("HasMany() = %v, want [true false]", found)
+	}
+}
+
+// TestDbGetManyRecordsAccessForKnownUnitsOnly checks recordAccess folds in
+// every returned unit with a dbID, under a single lock acquisition, and
+// leaves units without one (e.g. a miss with no dbPool to resolve it)
+// untouched.
+func TestDbGetManyRecordsAccessForKnownUnitsOnly(t *testing.T) {
+	cs := newTestACS(t)
+
+	dbID := uint64(42)
+	bu := &blockUnit{dbID: &dbID}
+	cs.recordAccess([]*blockUnit{bu, nil, {dbID: nil}}, GET)
+
+	if _, ok := cs.accessStatsRecent[dbID]; !ok {
+		t.Fatalf("recordAccess did not record dbID %d", dbID)
+	}
+	if len(cs.accessStatsRecent) != 1 {
+		t.Fatalf("accessStatsRecent has %d entries, want 1 (nil bu/dbID must be skipped)", len(cs.accessStatsRecent))
+	}
+
+	found := false
+	for au := range cs.accessStatsHiRes {
+		if au.dbID == dbID && au.accessType == GET {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("accessStatsHiRes missing an entry for dbID %d / accessType GET", dbID)
+	}
+}
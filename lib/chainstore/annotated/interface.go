@@ -2,6 +2,7 @@ package annotated
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
@@ -20,6 +21,19 @@ type Chainstore interface {
 	SetCurrentTipset(context.Context, *types.TipSet) (didChange bool, err error)
 }
 
+// BatchGetter is implemented by blockstores (namely *acs) that can answer
+// many lookups with a single round trip instead of one query per cid.
+// Callers holding only a blockstore.Blockstore can opportunistically
+// upgrade via a type assertion:
+//
+//	if bg, ok := bs.(annotated.BatchGetter); ok {
+//		blks, errs := bg.GetMany(cids)
+//	}
+type BatchGetter interface {
+	GetMany(cids []cid.Cid) ([]ipfsblock.Block, []error)
+	HasMany(cids []cid.Cid) ([]bool, error)
+}
+
 type acs struct {
 	linearSyncEventCount int64
 	cacheSize            int64
@@ -32,8 +46,33 @@ type acs struct {
 	limiterBlockParse    chan struct{}
 	limiterCompress      chan struct{}
 	mu                   sync.Mutex
+
+	// allKeysErr holds the error, if any, that ended the most recently
+	// completed AllKeysChan scan. Guarded by mu.
+	allKeysErr error
+
+	// limiterPrefetch bounds the number of in-flight speculative link-walk
+	// lookups (see prefetch). Nil disables prefetching entirely; it is set
+	// by the constructor from an opt-in "max in-flight" config knob.
+	limiterPrefetch chan struct{}
+	// prefetchMaxDepth bounds how many link hops a single Get/View's
+	// prefetch will chase before giving up, so a deep DAG doesn't turn one
+	// read into an unbounded recursive crawl.
+	prefetchMaxDepth int
+	// prefetchHydrate additionally decompresses prefetched blocks into
+	// hotCache; when false, prefetching only warms the primary ristretto
+	// cache via dbGet's own cache-population side effect.
+	prefetchHydrate bool
+	// hotCache is the secondary hot-block cache prefetchHydrate populates,
+	// separate from cache so speculative hydration can't evict blocks a
+	// real read already paid to warm.
+	hotCache *ristretto.Cache
 }
 
+// allKeysBatchSize is how many rows AllKeysChan FETCHes from its
+// server-side cursor per round trip.
+const allKeysBatchSize = 4096
+
 type blockUnit struct {
 	size              uint32
 	cid               cid.Cid
@@ -43,16 +82,75 @@ type blockUnit struct {
 	mu                sync.Mutex
 	errHolder         error
 	parsedLinks       []cid.Cid
+
+	// viewBuf is populated instead of hydratedBlock when this unit was
+	// fetched for VIEW access: dbGet decompresses compressedContent
+	// straight into a buffer drawn from viewBufPool, skipping the
+	// ipfsblock.Block construction and re-hash that Get pays for.
+	//
+	// *blockUnit is a cache-resident object shared across callers keyed by
+	// cid, so concurrent View(c) calls on the same cid share the same
+	// viewBuf. Every reader must check in via acquireView and check out
+	// via release; viewRefs (guarded by mu, alongside it) tracks how many
+	// readers currently hold it, so it's only zeroed and returned to
+	// viewBufPool once the last one is done, instead of one caller's
+	// release racing another's still-in-flight read.
+	viewBuf  *[]byte
+	viewRefs int
+}
+
+// acquireView checks in one reader of viewBuf, returning the bytes to
+// read. The caller must call release exactly once when done reading,
+// regardless of how many other callers are concurrently sharing this
+// cached unit's viewBuf.
+func (bu *blockUnit) acquireView() []byte {
+	bu.mu.Lock()
+	defer bu.mu.Unlock()
+	bu.viewRefs++
+	return *bu.viewBuf
+}
+
+// release checks in one reader's completion. viewBuf is only zeroed and
+// returned to viewBufPool once every concurrent acquireView caller has
+// released it. View callers must call this once their callback has
+// returned and is done reading the bytes.
+func (bu *blockUnit) release() {
+	bu.mu.Lock()
+	defer bu.mu.Unlock()
+
+	if bu.viewBuf == nil {
+		return
+	}
+	bu.viewRefs--
+	if bu.viewRefs > 0 {
+		return
+	}
+	*bu.viewBuf = (*bu.viewBuf)[:0]
+	viewBufPool.Put(bu.viewBuf)
+	bu.viewBuf = nil
 }
+
+// viewBufPool holds the pooled byte slices behind View's zero-copy
+// decompress path.
+var viewBufPool = sync.Pool{
+	New: func() interface{} { return new([]byte) },
+}
+
 type accessType uint8
 
 const (
-	MASKTYPE = 0b11
+	MASKTYPE = 0b111
 
 	PUT  = accessType(0)
 	GET  = accessType(1)
 	HAS  = accessType(2)
 	SIZE = accessType(3)
+	VIEW = accessType(4)
+
+	// PREFETCH marks lookups issued speculatively by the link-walk
+	// prefetcher rather than in direct response to a caller's Get/View, so
+	// the stats table can tell pipelined-ahead traffic from real reads.
+	PREFETCH = accessType(5)
 
 	// cache-modifier
 	PREEXISTING = accessType(1 << 6) // db R/W access skipped due to cache hit or already-existing entry
@@ -69,11 +167,112 @@ type accessUnit struct {
 func (*acs) DeleteBlock(cid.Cid) error {
 	panic("DeleteBlock is not implemented by the annotated blockstore")
 }
-func (*acs) AllKeysChan(context.Context) (<-chan cid.Cid, error) {
-	panic("AllKeysChan is not implemented by the annotated blockstore")
-}
 func (*acs) HashOnRead(bool) {} // just noop: we always hash
 
+// AllKeysChan opens a server-side cursor over the blocks table and streams
+// decoded CIDs back on the returned channel, fetching allKeysBatchSize rows
+// per round trip. It acquires a dedicated connection from dbPool for the
+// lifetime of the scan, since cursors in pgx are transaction-scoped, and
+// releases it once the scan finishes, the caller stops draining the
+// channel in response to ctx, or a pg error ends it early. Whichever of
+// those ended the scan is available afterwards via Err().
+func (cs *acs) AllKeysChan(ctx context.Context) (<-chan cid.Cid, error) {
+	conn, err := cs.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, "DECLARE allkeys_cursor CURSOR FOR SELECT cid FROM blocks"); err != nil {
+		tx.Rollback(ctx) // nolint:errcheck
+		conn.Release()
+		return nil, err
+	}
+
+	out := make(chan cid.Cid)
+	fetchSQL := fmt.Sprintf("FETCH FORWARD %d FROM allkeys_cursor", allKeysBatchSize)
+
+	go func() {
+		defer close(out)
+		defer conn.Release()
+
+		var scanErr error
+		defer func() {
+			if scanErr != nil {
+				tx.Rollback(ctx) // nolint:errcheck
+			} else {
+				tx.Commit(ctx) // nolint:errcheck
+			}
+			cs.mu.Lock()
+			cs.allKeysErr = scanErr
+			cs.mu.Unlock()
+		}()
+
+		for {
+			rows, err := tx.Query(ctx, fetchSQL)
+			if err != nil {
+				scanErr = err
+				return
+			}
+
+			n := 0
+			for rows.Next() {
+				n++
+
+				var raw []byte
+				if err := rows.Scan(&raw); err != nil {
+					rows.Close()
+					scanErr = err
+					return
+				}
+
+				c, err := cid.Cast(raw)
+				if err != nil {
+					rows.Close()
+					scanErr = err
+					return
+				}
+
+				select {
+				case out <- c:
+				case <-ctx.Done():
+					rows.Close()
+					scanErr = ctx.Err()
+					return
+				}
+			}
+
+			err = rows.Err()
+			rows.Close()
+			if err != nil {
+				scanErr = err
+				return
+			}
+
+			if n < allKeysBatchSize {
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Err returns the error, if any, that ended the most recently completed
+// AllKeysChan scan. It is nil before any scan has completed and after a
+// scan that ran to completion cleanly; while a scan is still in flight it
+// reports the previous scan's result.
+func (cs *acs) Err() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.allKeysErr
+}
+
 //
 // Writers
 func (cs *acs) Put(b ipfsblock.Block) error         { return cs.dbPut([]ipfsblock.Block{b}) }
@@ -116,16 +315,246 @@ func (cs *acs) Get(c cid.Cid) (ipfsblock.Block, error) {
 
 	case bu == nil:
 		return nil, blockstore.ErrNotFound
+	}
 
-	default:
-		return bu.block()
+	cs.prefetch(bu, 0)
+
+	return bu.block()
+}
+
+// prefetch speculatively warms bu's DAG-CBOR children after a successful
+// Get/View, since the state-tree walk that just asked for bu is, in
+// practice, about to ask for most of them next. It is opt-in (nil
+// limiterPrefetch disables it), best-effort (a saturated worker pool just
+// drops a link rather than block the caller), and depth-bounded so one
+// read can't turn into an unbounded recursive crawl.
+func (cs *acs) prefetch(bu *blockUnit, depth int) {
+	if cs.limiterPrefetch == nil || bu == nil || depth >= cs.prefetchMaxDepth {
+		return
+	}
+	if bu.cid.Prefix().Codec != cid.DagCBOR {
+		return
+	}
+
+	for _, link := range bu.parsedLinks {
+		select {
+		case cs.limiterPrefetch <- struct{}{}:
+		default:
+			// worker pool is saturated; this link just won't be warmed.
+			continue
+		}
+
+		link := link
+		go func() {
+			defer func() { <-cs.limiterPrefetch }()
+			cs.prefetchOne(link, depth+1)
+		}()
 	}
 }
 
-func (cs *acs) View(c cid.Cid, cb func([]byte) error) error {
-	blk, err := cs.Get(c)
+// prefetchOne issues a PREFETCH-tagged dbGet for c, which warms cs.cache
+// the same way a real read would, optionally hydrates the decompressed
+// bytes into hotCache, and recurses into c's own parsedLinks.
+func (cs *acs) prefetchOne(c cid.Cid, depth int) {
+	bu, err := cs.dbGet(c, PREFETCH)
+	if err != nil || bu == nil {
+		return
+	}
+
+	if cs.prefetchHydrate && cs.hotCache != nil {
+		if blk, err := bu.block(); err == nil {
+			cs.hotCache.Set(c, blk, int64(bu.size))
+		}
+	}
+
+	cs.prefetch(bu, depth)
+}
+
+// GetMany fetches many blocks with a single round trip to postgres for
+// whatever the cache doesn't already hold, instead of paying cs.Get's
+// query-per-cid cost. This mirrors the batching PutMany already applies on
+// the write side, and matters because a tipset's child fan-out during a
+// chain walk made the one-query-per-cid Get path pgxpool's dominant source
+// of contention.
+func (cs *acs) GetMany(cids []cid.Cid) ([]ipfsblock.Block, []error) {
+	bus, err := cs.dbGetMany(cids, GET)
+
+	blks := make([]ipfsblock.Block, len(cids))
+	errs := make([]error, len(cids))
+
 	if err != nil {
+		// dbGetMany returns (nil, err) on any pg failure; bus has no
+		// per-cid entries to range over in that case, so every result
+		// must report the same failure instead of silently coming back
+		// empty.
+		for i := range cids {
+			errs[i] = err
+		}
+		return blks, errs
+	}
+
+	for i, bu := range bus {
+		switch {
+
+		case bu == nil:
+			errs[i] = blockstore.ErrNotFound
+
+		default:
+			blks[i], errs[i] = bu.block()
+		}
+	}
+
+	return blks, errs
+}
+
+// HasMany is GetMany's cheaper sibling: the same batched cache-then-ANY($1)
+// lookup, but without decompressing anything it finds.
+func (cs *acs) HasMany(cids []cid.Cid) ([]bool, error) {
+	bus, err := cs.dbGetMany(cids, HAS)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]bool, len(cids))
+	for i, bu := range bus {
+		found[i] = bu != nil
+	}
+
+	return found, nil
+}
+
+// dbGetMany is GetMany/HasMany's shared batched path: check the ristretto
+// cache for every cid, then issue a single `SELECT ... WHERE cid =
+// ANY($1)` for whatever missed, decompressing the results in parallel
+// bounded by limiterCompress (skipped for HAS, which doesn't need the
+// bytes), and folding every hit -- cached or freshly fetched -- into
+// accessStatsRecent/accessStatsHiRes under one lock acquisition instead of
+// the one-lock-per-key cost of looping cs.dbGet.
+func (cs *acs) dbGetMany(cids []cid.Cid, at accessType) ([]*blockUnit, error) {
+	ctx := context.Background()
+
+	bus := make([]*blockUnit, len(cids))
+	miss := make([]int, 0, len(cids))
+
+	for i, c := range cids {
+		if v, ok := cs.cache.Get(c); ok {
+			bus[i] = v.(*blockUnit)
+			continue
+		}
+		miss = append(miss, i)
+	}
+
+	// with no dbPool there is nothing to query, so misses stay nil.
+	if len(miss) > 0 && cs.dbPool != nil {
+		missCids := make([][]byte, len(miss))
+		for j, i := range miss {
+			missCids[j] = cids[i].Bytes()
+		}
+
+		rows, err := cs.dbPool.Query(ctx, "SELECT cid, dbid, size, content FROM blocks WHERE cid = ANY($1)", missCids)
+		if err != nil {
+			return nil, err
+		}
+
+		found := make(map[cid.Cid]*blockUnit, len(miss))
+		for rows.Next() {
+			var (
+				raw        []byte
+				dbID       uint64
+				size       uint32
+				compressed []byte
+			)
+			if err := rows.Scan(&raw, &dbID, &size, &compressed); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			c, err := cid.Cast(raw)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			found[c] = &blockUnit{
+				cid:               c,
+				dbID:              &dbID,
+				size:              size,
+				compressedContent: compressed,
+			}
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rows.Close()
+
+		var wg sync.WaitGroup
+		for _, i := range miss {
+			bu, ok := found[cids[i]]
+			if !ok {
+				continue
+			}
+			bus[i] = bu
+
+			if at == HAS {
+				// Has doesn't need the bytes, so don't pay for decompression.
+				continue
+			}
+
+			wg.Add(1)
+			go func(bu *blockUnit) {
+				defer wg.Done()
+				cs.limiterCompress <- struct{}{}
+				defer func() { <-cs.limiterCompress }()
+				_, _ = bu.block() // warms bu.hydratedBlock/errHolder for the synchronous call below
+			}(bu)
+		}
+		wg.Wait()
+	}
+
+	cs.recordAccess(bus, at)
+
+	return bus, nil
+}
+
+// recordAccess folds every unit's access into accessStatsRecent/
+// accessStatsHiRes under one lock acquisition, instead of the
+// one-lock-acquisition-per-key cost of calling cs.dbGet in a loop.
+func (cs *acs) recordAccess(bus []*blockUnit, at accessType) {
+	now := time.Now()
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, bu := range bus {
+		if bu == nil || bu.dbID == nil {
+			continue
+		}
+		cs.accessStatsRecent[*bu.dbID] = struct{}{}
+		cs.accessStatsHiRes[accessUnit{atUnix: now, dbID: *bu.dbID, accessType: at}]++
+	}
+}
+
+// View implements a zero-copy fast path: dbGet(..., VIEW) decompresses
+// straight into a pooled buffer instead of building and hash-verifying a
+// full ipfsblock.Block the way Get does. This is the hot path for
+// state-tree traversal, which is the whole reason the Viewer interface
+// exists in the first place.
+func (cs *acs) View(c cid.Cid, cb func([]byte) error) error {
+	bu, err := cs.dbGet(c, VIEW)
+
+	switch {
+
+	case err != nil:
 		return err
+
+	case bu == nil:
+		return blockstore.ErrNotFound
 	}
-	return cb(blk.RawData())
+
+	cs.prefetch(bu, 0)
+
+	buf := bu.acquireView()
+	defer bu.release()
+	return cb(buf)
 }
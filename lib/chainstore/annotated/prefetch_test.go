@@ -0,0 +1,85 @@
+package annotated
+
+import (
+	"testing"
+
+	ipfsblock "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+)
+
+// TestPrefetchDisabledWithoutLimiter checks prefetch is a strict no-op when
+// limiterPrefetch is nil, the documented opt-out. A linked block is present
+// so a bug here would otherwise spawn a prefetchOne goroutine.
+func TestPrefetchDisabledWithoutLimiter(t *testing.T) {
+	cs := newTestACS(t)
+	cs.prefetchMaxDepth = 4
+
+	link := ipfsblock.NewBlock([]byte("child")).Cid()
+	bu := &blockUnit{cid: cidWithCodec(t, cid.DagCBOR), parsedLinks: []cid.Cid{link}}
+
+	cs.prefetch(bu, 0) // must not panic despite limiterPrefetch == nil
+}
+
+// TestPrefetchSkipsNonDagCBOR checks prefetch only chases links out of
+// DAG-CBOR nodes -- raw blocks have no parseable links to begin with.
+func TestPrefetchSkipsNonDagCBOR(t *testing.T) {
+	cs := newTestACS(t)
+	cs.prefetchMaxDepth = 4
+	cs.limiterPrefetch = make(chan struct{}, 4)
+
+	link := ipfsblock.NewBlock([]byte("child")).Cid()
+	bu := &blockUnit{cid: cidWithCodec(t, cid.Raw), parsedLinks: []cid.Cid{link}}
+
+	cs.prefetch(bu, 0)
+
+	if len(cs.limiterPrefetch) != 0 {
+		t.Fatalf("prefetch dispatched %d lookups for a non-DAG-CBOR unit, want 0", len(cs.limiterPrefetch))
+	}
+}
+
+// TestPrefetchStopsAtMaxDepth checks prefetch refuses to recurse once depth
+// reaches prefetchMaxDepth, the bound against turning one read into an
+// unbounded crawl.
+func TestPrefetchStopsAtMaxDepth(t *testing.T) {
+	cs := newTestACS(t)
+	cs.prefetchMaxDepth = 2
+	cs.limiterPrefetch = make(chan struct{}, 4)
+
+	link := ipfsblock.NewBlock([]byte("child")).Cid()
+	bu := &blockUnit{cid: cidWithCodec(t, cid.DagCBOR), parsedLinks: []cid.Cid{link}}
+
+	cs.prefetch(bu, 2) // depth == prefetchMaxDepth
+
+	if len(cs.limiterPrefetch) != 0 {
+		t.Fatalf("prefetch dispatched %d lookups at depth == prefetchMaxDepth, want 0", len(cs.limiterPrefetch))
+	}
+}
+
+// TestPrefetchSkipsWhenSaturated checks a full worker pool just drops a
+// link rather than blocking the caller, per prefetch's doc comment.
+func TestPrefetchSkipsWhenSaturated(t *testing.T) {
+	cs := newTestACS(t)
+	cs.prefetchMaxDepth = 4
+	cs.limiterPrefetch = make(chan struct{}) // zero capacity: always saturated
+
+	links := []cid.Cid{
+		ipfsblock.NewBlock([]byte("child one")).Cid(),
+		ipfsblock.NewBlock([]byte("child two")).Cid(),
+	}
+	bu := &blockUnit{cid: cidWithCodec(t, cid.DagCBOR), parsedLinks: links}
+
+	done := make(chan struct{})
+	go func() {
+		cs.prefetch(bu, 0)
+		close(done)
+	}()
+	<-done // would hang if prefetch blocked trying to check in a worker slot
+}
+
+// cidWithCodec returns a DAG-CBOR or Raw CID over arbitrary content, purely
+// to drive prefetch's codec check.
+func cidWithCodec(t *testing.T, codec uint64) cid.Cid {
+	t.Helper()
+	mh := ipfsblock.NewBlock([]byte("x")).Cid().Hash()
+	return cid.NewCidV1(codec, mh)
+}
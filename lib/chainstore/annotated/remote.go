@@ -0,0 +1,168 @@
+package annotated
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/blockstore"
+	ipfsblock "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+)
+
+// ChainIO is the read/write surface a cheap, dbPool-less Lotus node needs
+// from a remote annotated blockstore: the same ChainReadObj/ChainHasObj
+// pair the rest of Lotus already calls through the full node API, plus the
+// batch and tipset-bookkeeping calls this package adds on top. A concrete
+// ChainIO is whatever RPC client stub the caller dials in with (jsonrpc or
+// otherwise); this package only needs the interface.
+type ChainIO interface {
+	ChainReadObj(ctx context.Context, c cid.Cid) ([]byte, error)
+	ChainHasObj(ctx context.Context, c cid.Cid) (bool, error)
+	ChainReadObjMany(ctx context.Context, cids []cid.Cid) ([][]byte, []error)
+	ChainHasObjMany(ctx context.Context, cids []cid.Cid) ([]bool, error)
+	ChainSetCurrentTipset(ctx context.Context, ts *types.TipSet) (bool, error)
+}
+
+// ChainIOServer implements ChainIO on top of an *acs, so one
+// Postgres-backed annotated blockstore can be shared by many read-only
+// Lotus nodes that would otherwise each need their own dbPool. Every read
+// method goes through cs's own Get/Has/GetMany/HasMany, so a remote caller
+// is bound by the same limiterBlockParse/limiterCompress semaphores a
+// local caller already is, rather than being able to buy itself extra
+// concurrency just by being remote.
+type ChainIOServer struct {
+	cs *acs
+}
+
+// NewChainIOServer wraps cs for serving over whatever RPC transport the
+// caller exposes it on.
+func NewChainIOServer(cs *acs) *ChainIOServer {
+	return &ChainIOServer{cs: cs}
+}
+
+func (s *ChainIOServer) ChainReadObj(_ context.Context, c cid.Cid) ([]byte, error) {
+	blk, err := s.cs.Get(c)
+	if err != nil {
+		return nil, err
+	}
+	return blk.RawData(), nil
+}
+
+func (s *ChainIOServer) ChainHasObj(_ context.Context, c cid.Cid) (bool, error) {
+	return s.cs.Has(c)
+}
+
+// ChainReadObjMany is the batch counterpart to ChainReadObj, mapping
+// straight onto GetMany so remote callers don't regress to per-CID
+// latency for the chain-walk fan-out GetMany exists for.
+func (s *ChainIOServer) ChainReadObjMany(_ context.Context, cids []cid.Cid) ([][]byte, []error) {
+	blks, errs := s.cs.GetMany(cids)
+
+	raws := make([][]byte, len(blks))
+	for i, blk := range blks {
+		if blk != nil {
+			raws[i] = blk.RawData()
+		}
+	}
+	return raws, errs
+}
+
+// ChainHasObjMany is the batch counterpart to ChainHasObj, mapping
+// straight onto HasMany.
+func (s *ChainIOServer) ChainHasObjMany(_ context.Context, cids []cid.Cid) ([]bool, error) {
+	return s.cs.HasMany(cids)
+}
+
+func (s *ChainIOServer) ChainSetCurrentTipset(ctx context.Context, ts *types.TipSet) (bool, error) {
+	return s.cs.SetCurrentTipset(ctx, ts)
+}
+
+// remoteBlockstore is a read-only Chainstore backed by a ChainIO RPC
+// client, for cheap Lotus nodes that want the annotated blockstore's view
+// without carrying their own Postgres pool. Writes and full-store
+// enumeration aren't meaningful for a thin remote attachment, so they
+// return an error rather than silently behaving like a local store.
+type remoteBlockstore struct {
+	cio ChainIO
+}
+
+// NewAnnotatedRemoteBlockstore wraps cio -- typically a jsonrpc (or
+// similar) client stub dialed in against a ChainIOServer -- as a
+// Chainstore, so a read-only node can Get/Has/View/GetMany/HasMany
+// against one shared Postgres-backed instance instead of opening its own
+// dbPool.
+func NewAnnotatedRemoteBlockstore(cio ChainIO) (Chainstore, error) {
+	return &remoteBlockstore{cio: cio}, nil
+}
+
+func (r *remoteBlockstore) DeleteBlock(cid.Cid) error {
+	return xerrors.New("DeleteBlock is not supported by the remote annotated blockstore")
+}
+
+func (r *remoteBlockstore) Put(ipfsblock.Block) error {
+	return xerrors.New("Put is not supported by the remote annotated blockstore")
+}
+
+func (r *remoteBlockstore) PutMany([]ipfsblock.Block) error {
+	return xerrors.New("PutMany is not supported by the remote annotated blockstore")
+}
+
+func (r *remoteBlockstore) AllKeysChan(context.Context) (<-chan cid.Cid, error) {
+	return nil, xerrors.New("AllKeysChan is not supported by the remote annotated blockstore")
+}
+
+func (r *remoteBlockstore) HashOnRead(bool) {} // noop, same as *acs: we always hash
+
+func (r *remoteBlockstore) Has(c cid.Cid) (bool, error) {
+	return r.cio.ChainHasObj(context.TODO(), c)
+}
+
+func (r *remoteBlockstore) GetSize(c cid.Cid) (int, error) {
+	blk, err := r.Get(c)
+	if err != nil {
+		return -1, err
+	}
+	return len(blk.RawData()), nil
+}
+
+func (r *remoteBlockstore) Get(c cid.Cid) (ipfsblock.Block, error) {
+	data, err := r.cio.ChainReadObj(context.TODO(), c)
+	if err != nil {
+		return nil, err
+	}
+	return ipfsblock.NewBlockWithCid(data, c)
+}
+
+func (r *remoteBlockstore) View(c cid.Cid, cb func([]byte) error) error {
+	blk, err := r.Get(c)
+	if err != nil {
+		return err
+	}
+	return cb(blk.RawData())
+}
+
+// GetMany maps onto ChainReadObjMany so a remote Chainstore's chain-walk
+// fan-out costs one round trip, not one per CID.
+func (r *remoteBlockstore) GetMany(cids []cid.Cid) ([]ipfsblock.Block, []error) {
+	raws, errs := r.cio.ChainReadObjMany(context.TODO(), cids)
+
+	blks := make([]ipfsblock.Block, len(cids))
+	for i, raw := range raws {
+		if errs[i] != nil || raw == nil {
+			continue
+		}
+		blks[i], errs[i] = ipfsblock.NewBlockWithCid(raw, cids[i])
+	}
+	return blks, errs
+}
+
+// HasMany maps onto ChainHasObjMany.
+func (r *remoteBlockstore) HasMany(cids []cid.Cid) ([]bool, error) {
+	return r.cio.ChainHasObjMany(context.TODO(), cids)
+}
+
+func (r *remoteBlockstore) SetCurrentTipset(ctx context.Context, ts *types.TipSet) (bool, error) {
+	return r.cio.ChainSetCurrentTipset(ctx, ts)
+}
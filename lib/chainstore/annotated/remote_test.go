@@ -0,0 +1,141 @@
+package annotated
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	ipfsblock "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+)
+
+// fakeChainIO is a minimal in-memory ChainIO double, letting
+// remoteBlockstore's method wiring be checked without a real RPC client or
+// a Postgres-backed ChainIOServer on the other end.
+type fakeChainIO struct {
+	blocks map[cid.Cid][]byte
+}
+
+func (f *fakeChainIO) ChainReadObj(_ context.Context, c cid.Cid) ([]byte, error) {
+	data, ok := f.blocks[c]
+	if !ok {
+		return nil, xerrors.New("not found")
+	}
+	return data, nil
+}
+
+func (f *fakeChainIO) ChainHasObj(_ context.Context, c cid.Cid) (bool, error) {
+	_, ok := f.blocks[c]
+	return ok, nil
+}
+
+func (f *fakeChainIO) ChainReadObjMany(_ context.Context, cids []cid.Cid) ([][]byte, []error) {
+	raws := make([][]byte, len(cids))
+	errs := make([]error, len(cids))
+	for i, c := range cids {
+		raws[i], errs[i] = f.ChainReadObj(context.Background(), c)
+	}
+	return raws, errs
+}
+
+func (f *fakeChainIO) ChainHasObjMany(_ context.Context, cids []cid.Cid) ([]bool, error) {
+	found := make([]bool, len(cids))
+	for i, c := range cids {
+		found[i], _ = f.ChainHasObj(context.Background(), c)
+	}
+	return found, nil
+}
+
+func (f *fakeChainIO) ChainSetCurrentTipset(context.Context, *types.TipSet) (bool, error) {
+	return true, nil
+}
+
+// TestRemoteBlockstoreReadPath checks Get/Has/View/GetMany/HasMany all map
+// onto the expected ChainIO calls and surface its data/errors unchanged.
+func TestRemoteBlockstoreReadPath(t *testing.T) {
+	blk := ipfsblock.NewBlock([]byte("a block"))
+	cio := &fakeChainIO{blocks: map[cid.Cid][]byte{blk.Cid(): blk.RawData()}}
+
+	bs, err := NewAnnotatedRemoteBlockstore(cio)
+	if err != nil {
+		t.Fatalf("NewAnnotatedRemoteBlockstore: %s", err)
+	}
+
+	if found, err := bs.Has(blk.Cid()); err != nil || !found {
+		t.Fatalf("Has(present) = %v, %v; want true, nil", found, err)
+	}
+
+	missing := ipfsblock.NewBlock([]byte("missing")).Cid()
+	if found, err := bs.Has(missing); err != nil || found {
+		t.Fatalf("Has(missing) = %v, %v; want false, nil", found, err)
+	}
+
+	got, err := bs.Get(blk.Cid())
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	if string(got.RawData()) != string(blk.RawData()) {
+		t.Fatalf("Get() = %q, want %q", got.RawData(), blk.RawData())
+	}
+
+	if _, err := bs.Get(missing); err == nil {
+		t.Fatalf("Get(missing) succeeded, want an error")
+	}
+
+	var viewed []byte
+	if err := bs.View(blk.Cid(), func(b []byte) error {
+		viewed = append([]byte{}, b...)
+		return nil
+	}); err != nil {
+		t.Fatalf("View: %s", err)
+	}
+	if string(viewed) != string(blk.RawData()) {
+		t.Fatalf("View() = %q, want %q", viewed, blk.RawData())
+	}
+
+	bg, ok := bs.(BatchGetter)
+	if !ok {
+		t.Fatalf("remoteBlockstore does not implement BatchGetter")
+	}
+
+	blks, errs := bg.GetMany([]cid.Cid{blk.Cid(), missing})
+	if errs[0] != nil || string(blks[0].RawData()) != string(blk.RawData()) {
+		t.Fatalf("GetMany()[0] = %v, %v; want %q, nil", blks[0], errs[0], blk.RawData())
+	}
+	if errs[1] == nil {
+		t.Fatalf("GetMany()[1] err = nil, want an error for the missing cid")
+	}
+
+	found, err := bg.HasMany([]cid.Cid{blk.Cid(), missing})
+	if err != nil {
+		t.Fatalf("HasMany: %s", err)
+	}
+	if !found[0] || found[1] {
+		t.Fatalf("HasMany() = %v, want [true false]", found)
+	}
+}
+
+// TestRemoteBlockstoreWritesUnsupported checks that the thin remote
+// attachment refuses writes and full-store enumeration rather than
+// silently behaving like a local store, per its doc comment.
+func TestRemoteBlockstoreWritesUnsupported(t *testing.T) {
+	bs, err := NewAnnotatedRemoteBlockstore(&fakeChainIO{blocks: map[cid.Cid][]byte{}})
+	if err != nil {
+		t.Fatalf("NewAnnotatedRemoteBlockstore: %s", err)
+	}
+
+	blk := ipfsblock.NewBlock([]byte("x"))
+	if err := bs.Put(blk); err == nil {
+		t.Fatalf("Put succeeded, want an error")
+	}
+	if err := bs.PutMany([]ipfsblock.Block{blk}); err == nil {
+		t.Fatalf("PutMany succeeded, want an error")
+	}
+	if err := bs.DeleteBlock(blk.Cid()); err == nil {
+		t.Fatalf("DeleteBlock succeeded, want an error")
+	}
+	if _, err := bs.AllKeysChan(context.Background()); err == nil {
+		t.Fatalf("AllKeysChan succeeded, want an error")
+	}
+}